@@ -3,5 +3,21 @@ package constant
 import "errors"
 
 var (
-	ErrWriteOperationOnReadDB = errors.New("write operation attempted on read-only database")
+	ErrWriteOperationOnReadDB    = errors.New("write operation attempted on read-only database")
+	ErrWriteSessionOnReadDB      = errors.New("session options enabling writes are not allowed on read-only database")
+	ErrWriteClauseOnReadDB       = errors.New("clauses implying writes are not allowed on read-only database")
+	ErrLockOnReadDB              = errors.New("row locks are not allowed on read-only database")
+	ErrWriteTransactionOnReadDB  = errors.New("transactions on read-only database must be read only")
+	ErrNestedIsolation           = errors.New("nested transaction cannot use a stricter isolation level than the outer one")
+	ErrUnsupportedDialect        = errors.New("dialect is not supported")
+	ErrInvalidPageSize           = errors.New("page size must be positive")
+	ErrInvalidCursor             = errors.New("invalid pagination cursor")
+	ErrUnknownColumn             = errors.New("column is not a field of the model")
+	ErrNoReplicas                = errors.New("at least one read replica is required")
+	ErrReplicationStopped        = errors.New("replica is not streaming from the primary")
+	ErrLockOutsideTransaction    = errors.New("row locks can only be taken inside a transaction")
+	ErrInvalidLockOptions        = errors.New("SkipLocked and NoWait cannot be combined")
+	ErrEnqueueOutsideTransaction = errors.New("outbox events can only be enqueued inside a transaction")
+	ErrStaleObject               = errors.New("record was changed by someone else since it was read")
+	ErrLeaseLost                 = errors.New("outbox message was claimed by another relay before its outcome was recorded")
 )
@@ -0,0 +1,88 @@
+package balancer
+
+import (
+	"context"
+	"math/rand/v2"
+	"sync/atomic"
+)
+
+// Balancer chooses the replica a read chain runs on. replicas is never empty.
+type Balancer interface {
+	Pick(ctx context.Context, replicas []*Replica) *Replica
+}
+
+type roundRobin struct {
+	next atomic.Uint64
+}
+
+func NewRoundRobin() Balancer {
+	return &roundRobin{}
+}
+
+func (b *roundRobin) Pick(_ context.Context, replicas []*Replica) *Replica {
+	n := b.next.Add(1) - 1
+	return replicas[n%uint64(len(replicas))]
+}
+
+type random struct{}
+
+func NewRandom() Balancer {
+	return random{}
+}
+
+func (random) Pick(_ context.Context, replicas []*Replica) *Replica {
+	return replicas[rand.IntN(len(replicas))]
+}
+
+type weighted struct{}
+
+// NewWeighted picks replicas at random in proportion to their Weight.
+// Replicas with a non-positive weight count as weight 1.
+func NewWeighted() Balancer {
+	return weighted{}
+}
+
+func (weighted) Pick(_ context.Context, replicas []*Replica) *Replica {
+	total := 0
+	for _, replica := range replicas {
+		total += weightOf(replica)
+	}
+	n := rand.IntN(total)
+	for _, replica := range replicas {
+		n -= weightOf(replica)
+		if n < 0 {
+			return replica
+		}
+	}
+	return replicas[len(replicas)-1]
+}
+
+func weightOf(replica *Replica) int {
+	if replica.Weight <= 0 {
+		return 1
+	}
+	return replica.Weight
+}
+
+type leastInFlight struct {
+	offset atomic.Uint64
+}
+
+// NewLeastInFlight picks the replica with the fewest executing statements.
+// Replicas must be tracked with Replica.Track for the counts to move.
+func NewLeastInFlight() Balancer {
+	return &leastInFlight{}
+}
+
+func (b *leastInFlight) Pick(_ context.Context, replicas []*Replica) *Replica {
+	// rotate the starting point so ties are spread across replicas
+	start := int((b.offset.Add(1) - 1) % uint64(len(replicas)))
+	best := replicas[start]
+	for i := 1; i < len(replicas); i++ {
+		replica := replicas[(start+i)%len(replicas)]
+		if replica.InFlight() < best.InFlight() {
+			best = replica
+		}
+	}
+	return best
+}
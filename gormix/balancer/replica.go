@@ -0,0 +1,78 @@
+package balancer
+
+import (
+	"gorm.io/gorm"
+	"sync/atomic"
+)
+
+const inFlightKey = "spreaddb:in_flight"
+
+type Replica struct {
+	Name   string
+	DB     *gorm.DB
+	Weight int
+
+	inFlight *inFlight
+}
+
+func NewReplica(name string, db *gorm.DB, weight int) *Replica {
+	return &Replica{Name: name, DB: db, Weight: weight}
+}
+
+// InFlight reports the number of statements currently executing on the replica.
+func (r *Replica) InFlight() int64 {
+	if r.inFlight == nil {
+		return 0
+	}
+	return r.inFlight.n.Load()
+}
+
+// Track registers callbacks on the replica's connection that keep InFlight up
+// to date. Replicas sharing a gorm.DB share its count, and tracking one twice
+// is a no-op.
+func (r *Replica) Track() error {
+	if plugin, ok := r.DB.Config.Plugins[inFlightKey].(*inFlight); ok {
+		r.inFlight = plugin
+		return nil
+	}
+	plugin := &inFlight{}
+	if err := r.DB.Use(plugin); err != nil {
+		return err
+	}
+	r.inFlight = plugin
+	return nil
+}
+
+// inFlight counts the statements executing on the gorm.DB it is registered on.
+type inFlight struct {
+	n atomic.Int64
+}
+
+func (p *inFlight) Name() string {
+	return inFlightKey
+}
+
+func (p *inFlight) Initialize(db *gorm.DB) error {
+	start := func(db *gorm.DB) {
+		p.n.Add(1)
+		db.InstanceSet(inFlightKey, true)
+	}
+	end := func(db *gorm.DB) {
+		if _, ok := db.InstanceGet(inFlightKey); ok {
+			p.n.Add(-1)
+		}
+	}
+
+	query := db.Callback().Query()
+	if err := query.Before("gorm:query").Register("spreaddb:in_flight_start", start); err != nil {
+		return err
+	}
+	if err := query.After("gorm:query").Register("spreaddb:in_flight_end", end); err != nil {
+		return err
+	}
+	row := db.Callback().Row()
+	if err := row.Before("gorm:row").Register("spreaddb:in_flight_start", start); err != nil {
+		return err
+	}
+	return row.After("gorm:row").Register("spreaddb:in_flight_end", end)
+}
@@ -0,0 +1,45 @@
+package consistency
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Session remembers the last write made with a context so that later reads
+// with the same context can see it.
+type Session struct {
+	mu        sync.Mutex
+	lastWrite time.Time
+	lsn       string
+}
+
+type sessionKey struct{}
+
+// NewContext attaches a fresh Session to ctx, unless ctx already carries one.
+func NewContext(ctx context.Context) context.Context {
+	if _, ok := FromContext(ctx); ok {
+		return ctx
+	}
+	return context.WithValue(ctx, sessionKey{}, &Session{})
+}
+
+func FromContext(ctx context.Context) (*Session, bool) {
+	session, ok := ctx.Value(sessionKey{}).(*Session)
+	return session, ok
+}
+
+// LastWrite returns when the session last wrote and, if it was captured, the
+// primary's WAL position right after that write.
+func (s *Session) LastWrite() (time.Time, string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastWrite, s.lsn
+}
+
+func (s *Session) record(at time.Time, lsn string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastWrite = at
+	s.lsn = lsn
+}
@@ -0,0 +1,143 @@
+package consistency
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"time"
+)
+
+const PluginName = "spreaddb:consistency"
+
+type Config struct {
+	// Window after a write during which reads of the same session are kept consistent. Defaults to 5s.
+	Window time.Duration
+	// WaitForReplica captures the primary's LSN after each write and lets reads
+	// use a replica once it has replayed it, instead of always going to the primary.
+	// Postgres only.
+	WaitForReplica bool
+	// WaitTimeout bounds how long a read waits for its replica before using the primary. Defaults to 1s.
+	WaitTimeout time.Duration
+	// PollInterval between two replay checks while waiting. Defaults to 10ms.
+	PollInterval time.Duration
+}
+
+func (c Config) withDefaults() Config {
+	if c.Window <= 0 {
+		c.Window = 5 * time.Second
+	}
+	if c.WaitTimeout <= 0 {
+		c.WaitTimeout = time.Second
+	}
+	if c.PollInterval <= 0 {
+		c.PollInterval = 10 * time.Millisecond
+	}
+	return c
+}
+
+// Tracker is a gorm plugin for the primary that stamps the context's Session
+// after every successful write.
+type Tracker struct {
+	cfg Config
+}
+
+func NewTracker(cfg Config) *Tracker {
+	return &Tracker{cfg: cfg.withDefaults()}
+}
+
+func (t *Tracker) Name() string {
+	return PluginName
+}
+
+func (t *Tracker) Initialize(db *gorm.DB) error {
+	callback := db.Callback()
+	if err := callback.Create().After("gorm:commit_or_rollback_transaction").Register("spreaddb:consistency", t.afterWrite); err != nil {
+		return err
+	}
+	if err := callback.Update().After("gorm:commit_or_rollback_transaction").Register("spreaddb:consistency", t.afterWrite); err != nil {
+		return err
+	}
+	if err := callback.Delete().After("gorm:commit_or_rollback_transaction").Register("spreaddb:consistency", t.afterWrite); err != nil {
+		return err
+	}
+	return callback.Raw().After("gorm:raw").Register("spreaddb:consistency", t.afterWrite)
+}
+
+func (t *Tracker) afterWrite(db *gorm.DB) {
+	if db.Error != nil || db.DryRun {
+		return
+	}
+	session, ok := FromContext(db.Statement.Context)
+	if !ok {
+		return
+	}
+	if _, inTx := db.Statement.ConnPool.(gorm.TxCommitter); inTx {
+		// not visible to anyone until commit; keep the session on the primary meanwhile
+		session.record(time.Now(), "")
+		return
+	}
+	t.stamp(db, session)
+}
+
+func (t *Tracker) stamp(db *gorm.DB, session *Session) {
+	var lsn string
+	if t.cfg.WaitForReplica {
+		// read on the root pool: db may hold a transaction that was just committed
+		row := db.ConnPool.QueryRowContext(db.Statement.Context, "SELECT pg_current_wal_lsn()::text")
+		if err := row.Scan(&lsn); err != nil {
+			lsn = ""
+		}
+	}
+	session.record(time.Now(), lsn)
+}
+
+// Committed stamps the session of db's context after an explicit commit. It is
+// a no-op when db's primary has no Tracker or the context has no Session.
+func Committed(db *gorm.DB) {
+	t, ok := db.Config.Plugins[PluginName].(*Tracker)
+	if !ok {
+		return
+	}
+	session, ok := FromContext(db.Statement.Context)
+	if !ok {
+		return
+	}
+	t.stamp(db, session)
+}
+
+// CanRead reports whether replica may serve a read made with ctx. Inside the
+// window after a write it may only if WaitForReplica is set and replica replays
+// the write's LSN within WaitTimeout.
+func (t *Tracker) CanRead(ctx context.Context, replica *gorm.DB) bool {
+	session, ok := FromContext(ctx)
+	if !ok {
+		return true
+	}
+	lastWrite, lsn := session.LastWrite()
+	if lastWrite.IsZero() || time.Since(lastWrite) > t.cfg.Window {
+		return true
+	}
+	if !t.cfg.WaitForReplica || lsn == "" {
+		return false
+	}
+	return t.waitForReplay(ctx, replica, lsn)
+}
+
+func (t *Tracker) waitForReplay(ctx context.Context, replica *gorm.DB, lsn string) bool {
+	ctx, cancel := context.WithTimeout(ctx, t.cfg.WaitTimeout)
+	defer cancel()
+
+	ticker := time.NewTicker(t.cfg.PollInterval)
+	defer ticker.Stop()
+	for {
+		var replayed bool
+		err := replica.WithContext(ctx).Raw("SELECT pg_last_wal_replay_lsn() >= ?::pg_lsn", lsn).Row().Scan(&replayed)
+		if err == nil && replayed {
+			return true
+		}
+		select {
+		case <-ctx.Done():
+			return false
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package gormix
+
+import "context"
+
+type primaryKey struct{}
+
+// WithPrimary makes ReadOnlyDB chains started with WithContext(ctx) run on the
+// primary. The chain still only exposes read operations.
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+func PrimaryRequested(ctx context.Context) bool {
+	primary, _ := ctx.Value(primaryKey{}).(bool)
+	return primary
+}
+
+// Propagation controls how WriteOnlyDB.WithContext treats a transaction carried by the context.
+type Propagation int
+
+const (
+	// PropagationRequired joins the transaction in the context, if any. A
+	// Transaction call on the joined chain runs in a savepoint.
+	PropagationRequired Propagation = iota
+	// PropagationRequiresNew ignores the transaction in the context, so a
+	// Transaction call begins an independent one on another connection.
+	PropagationRequiresNew
+)
+
+type propagationKey struct{}
+
+type txKey struct{}
+
+func WithPropagation(ctx context.Context, propagation Propagation) context.Context {
+	return context.WithValue(ctx, propagationKey{}, propagation)
+}
+
+func PropagationFromContext(ctx context.Context) Propagation {
+	propagation, _ := ctx.Value(propagationKey{}).(Propagation)
+	return propagation
+}
+
+// WithTx records tx as the active transaction of ctx and resets the propagation
+// to PropagationRequired. WriteOnlyDB.Transaction does this for the context it
+// hands to its callback.
+func WithTx(ctx context.Context, tx WriteOnlyDB) context.Context {
+	ctx = context.WithValue(ctx, txKey{}, tx)
+	return WithPropagation(ctx, PropagationRequired)
+}
+
+func TxFromContext(ctx context.Context) (WriteOnlyDB, bool) {
+	tx, ok := ctx.Value(txKey{}).(WriteOnlyDB)
+	return tx, ok
+}
@@ -0,0 +1,154 @@
+package health
+
+import (
+	"context"
+	"github.com/XuanHieuHo/spread-db/gormix"
+	"github.com/XuanHieuHo/spread-db/gormix/balancer"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type Config struct {
+	// Interval between two rounds of pings. Defaults to 5s.
+	Interval time.Duration
+	// Timeout of a single ping. Defaults to 2s.
+	Timeout time.Duration
+	// FailureThreshold is the number of consecutive failed pings that ejects a replica. Defaults to 3.
+	FailureThreshold int
+	// SuccessThreshold is the number of consecutive successful pings that re-admits a replica. Defaults to 2.
+	SuccessThreshold int
+}
+
+func (c Config) withDefaults() Config {
+	if c.Interval <= 0 {
+		c.Interval = 5 * time.Second
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = 2 * time.Second
+	}
+	if c.FailureThreshold <= 0 {
+		c.FailureThreshold = 3
+	}
+	if c.SuccessThreshold <= 0 {
+		c.SuccessThreshold = 2
+	}
+	return c
+}
+
+type Status struct {
+	Replica              string
+	Healthy              bool
+	ConsecutiveFailures  int
+	ConsecutiveSuccesses int
+	LastError            error
+	LastCheckedAt        time.Time
+}
+
+type state struct {
+	replica *balancer.Replica
+	healthy atomic.Bool
+
+	mu     sync.Mutex
+	status Status
+}
+
+// Checker pings replicas in the background and tracks which of them may serve reads.
+// Replicas start out healthy.
+type Checker struct {
+	cfg    Config
+	states []*state
+	index  map[*balancer.Replica]*state
+
+	loop gormix.Loop
+}
+
+func NewChecker(replicas []*balancer.Replica, cfg Config) *Checker {
+	c := &Checker{
+		cfg:   cfg.withDefaults(),
+		index: make(map[*balancer.Replica]*state, len(replicas)),
+	}
+	for _, replica := range replicas {
+		s := &state{replica: replica, status: Status{Replica: replica.Name, Healthy: true}}
+		s.healthy.Store(true)
+		c.states = append(c.states, s)
+		c.index[replica] = s
+	}
+	return c
+}
+
+// Start runs a first round of checks immediately and then one every Interval until Stop.
+func (c *Checker) Start() {
+	c.loop.Start(c.cfg.Interval, c.CheckNow)
+}
+
+func (c *Checker) Stop() {
+	c.loop.Stop()
+}
+
+// CheckNow pings every replica once, concurrently, and updates their health.
+func (c *Checker) CheckNow(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, s := range c.states {
+		wg.Add(1)
+		go func(s *state) {
+			defer wg.Done()
+			err := c.ping(ctx, s.replica)
+			if ctx.Err() != nil {
+				// cancelled by Stop, not a verdict on the replica
+				return
+			}
+			c.record(s, err)
+		}(s)
+	}
+	wg.Wait()
+}
+
+func (c *Checker) ping(ctx context.Context, replica *balancer.Replica) error {
+	sqlDB, err := replica.DB.DB()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, c.cfg.Timeout)
+	defer cancel()
+	return sqlDB.PingContext(ctx)
+}
+
+func (c *Checker) record(s *state, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.status.LastError = err
+	s.status.LastCheckedAt = time.Now()
+	if err != nil {
+		s.status.ConsecutiveFailures++
+		s.status.ConsecutiveSuccesses = 0
+		if s.status.ConsecutiveFailures >= c.cfg.FailureThreshold {
+			s.status.Healthy = false
+		}
+	} else {
+		s.status.ConsecutiveSuccesses++
+		s.status.ConsecutiveFailures = 0
+		if s.status.ConsecutiveSuccesses >= c.cfg.SuccessThreshold {
+			s.status.Healthy = true
+		}
+	}
+	s.healthy.Store(s.status.Healthy)
+}
+
+// Healthy reports whether replica is in rotation. Unknown replicas are never healthy.
+func (c *Checker) Healthy(replica *balancer.Replica) bool {
+	s, ok := c.index[replica]
+	return ok && s.healthy.Load()
+}
+
+// Status returns a snapshot of every replica's health, in registration order.
+func (c *Checker) Status() []Status {
+	statuses := make([]Status, 0, len(c.states))
+	for _, s := range c.states {
+		s.mu.Lock()
+		statuses = append(statuses, s.status)
+		s.mu.Unlock()
+	}
+	return statuses
+}
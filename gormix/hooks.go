@@ -0,0 +1,37 @@
+package gormix
+
+import "gorm.io/gorm"
+
+// RegisterAround registers before and after on every statement kind, around all
+// other callbacks, as name+"_start" and name+"_end". Both are passed the kind of
+// the statement: create, query, update, delete, row or raw. A nil callback is
+// not registered.
+func RegisterAround(db *gorm.DB, name string, before, after func(operation string, db *gorm.DB)) error {
+	callback := db.Callback()
+	hooks := []struct {
+		operation string
+		before    func(name string, fn func(*gorm.DB)) error
+		after     func(name string, fn func(*gorm.DB)) error
+	}{
+		{"create", callback.Create().Before("*").Register, callback.Create().After("*").Register},
+		{"query", callback.Query().Before("*").Register, callback.Query().After("*").Register},
+		{"update", callback.Update().Before("*").Register, callback.Update().After("*").Register},
+		{"delete", callback.Delete().Before("*").Register, callback.Delete().After("*").Register},
+		{"row", callback.Row().Before("*").Register, callback.Row().After("*").Register},
+		{"raw", callback.Raw().Before("*").Register, callback.Raw().After("*").Register},
+	}
+	for _, hook := range hooks {
+		operation := hook.operation
+		if before != nil {
+			if err := hook.before(name+"_start", func(db *gorm.DB) { before(operation, db) }); err != nil {
+				return err
+			}
+		}
+		if after != nil {
+			if err := hook.after(name+"_end", func(db *gorm.DB) { after(operation, db) }); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
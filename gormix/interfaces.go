@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
 )
 
 type ReadOnlyDB interface {
@@ -13,7 +14,10 @@ type ReadOnlyDB interface {
 	Model(value interface{}) ReadOnlyDB
 	Select(query interface{}, args ...interface{}) ReadOnlyDB
 	Where(query interface{}, args ...interface{}) ReadOnlyDB
+	Or(query interface{}, args ...interface{}) ReadOnlyDB
+	Not(query interface{}, args ...interface{}) ReadOnlyDB
 	Joins(query string, args ...interface{}) ReadOnlyDB
+	InnerJoins(query string, args ...interface{}) ReadOnlyDB
 	Group(name string) ReadOnlyDB
 	Having(query interface{}, args ...interface{}) ReadOnlyDB
 	Order(value interface{}) ReadOnlyDB
@@ -25,24 +29,35 @@ type ReadOnlyDB interface {
 	Distinct(args ...interface{}) ReadOnlyDB
 	Omit(columns ...string) ReadOnlyDB
 	Raw(sql string, values ...interface{}) ReadOnlyDB
+	Clauses(conds ...clause.Expression) ReadOnlyDB
 
 	// Read Operations
 	Find(dest interface{}, conds ...interface{}) ReadOnlyDB
 	First(dest interface{}, conds ...interface{}) ReadOnlyDB
+	FirstOrInit(dest interface{}, conds ...interface{}) ReadOnlyDB
 	Last(dest interface{}, conds ...interface{}) ReadOnlyDB
 	Take(dest interface{}, conds ...interface{}) ReadOnlyDB
 	Scan(dest interface{}) ReadOnlyDB
 	Pluck(column string, dest interface{}) ReadOnlyDB
 	Count(count *int64) ReadOnlyDB
+	FindInBatches(dest interface{}, batchSize int, fc func(tx ReadOnlyDB, batch int) error) ReadOnlyDB
 	Row() *sql.Row
 	Rows() (*sql.Rows, error)
+	ScanRows(rows *sql.Rows, dest interface{}) error
+
+	// Transaction
+	Connection(fc func(tx ReadOnlyDB) error) error
+	Transaction(fc func(tx ReadOnlyDB) error, opts ...*sql.TxOptions) error
+	ReadSnapshot(fc func(tx ReadOnlyDB) error, opts ...SnapshotOptions) error
 
 	// Debug
 	Debug() ReadOnlyDB
 
-	Statement() *gorm.Statement
+	Statement() StatementView
+	// Parse returns the schema of value without running a statement.
+	Parse(value interface{}) (*schema.Schema, error)
 	Error() error
-	Dialector() gorm.Dialector
+	Dialector() Dialect
 	Session(session *gorm.Session) ReadOnlyDB
 }
 
@@ -96,12 +111,25 @@ type WriteOnlyDB interface {
 	UpdateColumns(values interface{}) WriteOnlyDB
 	Delete(value interface{}, conds ...interface{}) WriteOnlyDB
 	Exec(sql string, values ...interface{}) WriteOnlyDB
+	Upsert(value interface{}, conflictColumns []string, updateColumns ...string) WriteOnlyDB
+	InsertIgnore(value interface{}) WriteOnlyDB
+	FirstOrCreate(dest interface{}, conds ...interface{}) WriteOnlyDB
+	FirstOrInit(dest interface{}, conds ...interface{}) WriteOnlyDB
+	Attrs(attrs ...interface{}) WriteOnlyDB
+	Assign(attrs ...interface{}) WriteOnlyDB
+
+	// Row locks, only inside a transaction
+	LockForUpdate(opts ...LockOptions) WriteOnlyDB
+	LockForShare(opts ...LockOptions) WriteOnlyDB
 
 	// Transaction
 	Transaction(fc func(tx WriteOnlyDB) error, opts ...*sql.TxOptions) error
 	Begin(opts ...*sql.TxOptions) WriteOnlyDB
 	Commit() error
 	Rollback() error
+	SavePoint(name string) WriteOnlyDB
+	RollbackTo(name string) WriteOnlyDB
+	ReleaseSavePoint(name string) WriteOnlyDB
 
 	// Association methods
 	Association(column string) *gorm.Association
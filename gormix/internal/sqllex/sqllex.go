@@ -0,0 +1,206 @@
+// Package sqllex splits SQL text into tokens. It is shared by the read-only
+// guard and the query normalizer so that both agree on where literals,
+// identifiers and comments start and end.
+package sqllex
+
+import (
+	"strings"
+)
+
+type Kind int
+
+const (
+	// Word is a bare identifier or keyword.
+	Word Kind = iota
+	// String is a string literal, including prefixed (E'', N'', X'') and
+	// dollar-quoted ones, and double-quoted strings on MySQL.
+	String
+	// Number is a numeric literal, including hexadecimal ones such as 0xFF.
+	Number
+	// QuotedIdent is a quoted identifier: "name", or `name` on MySQL.
+	QuotedIdent
+	// Param is a positional placeholder such as $1.
+	Param
+	// Comment is a line or block comment. A line comment stops before its newline.
+	Comment
+	// Other is any other single byte, such as whitespace, punctuation or ?, and
+	// the /*! opener of a MySQL executable comment, whose body is tokenized.
+	Other
+)
+
+type Token struct {
+	Kind Kind
+	Text string
+}
+
+// Tokenize splits query into tokens following the rules of dialect, the gorm
+// dialector name. Concatenating the texts of the tokens gives back query.
+func Tokenize(query string, dialect string) []Token {
+	mysql := dialect == "mysql"
+
+	var tokens []Token
+	for i := 0; i < len(query); {
+		kind, end := next(query, i, mysql)
+		tokens = append(tokens, Token{Kind: kind, Text: query[i:end]})
+		i = end
+	}
+	return tokens
+}
+
+// next returns the kind and end of the token starting at query[i].
+func next(query string, i int, mysql bool) (Kind, int) {
+	c := query[i]
+	switch {
+	case c == '\'':
+		return String, skipQuoted(query, i, '\'', mysql)
+	case c == '"' && mysql:
+		return String, skipQuoted(query, i, '"', true)
+	case c == '"':
+		return QuotedIdent, skipQuoted(query, i, '"', false)
+	case c == '`' && mysql:
+		return QuotedIdent, skipQuoted(query, i, '`', false)
+	case c == '-' && i+1 < len(query) && query[i+1] == '-', c == '#' && mysql:
+		return Comment, skipLine(query, i)
+	case c == '/' && i+2 < len(query) && query[i+1] == '*' && query[i+2] == '!' && mysql:
+		// MySQL executes the body of /*! ... */ comments, so it is tokenized.
+		return Other, i + 3
+	case c == '/' && i+1 < len(query) && query[i+1] == '*':
+		return Comment, skipBlockComment(query, i, !mysql)
+	case c == '$' && !mysql && i+1 < len(query) && isDigit(query[i+1]):
+		end := i + 1
+		for end < len(query) && isDigit(query[end]) {
+			end++
+		}
+		return Param, end
+	case c == '$' && !mysql:
+		if end := skipDollar(query, i); end > i+1 {
+			return String, end
+		}
+		return Other, i + 1
+	case isDigit(c) || (c == '.' && i+1 < len(query) && isDigit(query[i+1])):
+		return Number, skipNumber(query, i)
+	case isWordStart(c):
+		end := i
+		for end < len(query) && isWordPart(query[end]) {
+			end++
+		}
+		if end-i == 1 && end < len(query) && query[end] == '\'' {
+			switch c {
+			case 'E', 'e':
+				// Postgres escape string, the only literal there where backslashes escape
+				return String, skipQuoted(query, end, '\'', true)
+			case 'N', 'n', 'X', 'x', 'B', 'b':
+				return String, skipQuoted(query, end, '\'', mysql)
+			}
+		}
+		return Word, end
+	}
+	return Other, i + 1
+}
+
+// skipQuoted returns the index just past the literal opened at query[start].
+// A doubled quote is always an escape; backslash escapes are honoured when requested.
+func skipQuoted(query string, start int, quote byte, backslash bool) int {
+	for i := start + 1; i < len(query); i++ {
+		switch query[i] {
+		case '\\':
+			if backslash {
+				i++
+			}
+		case quote:
+			if i+1 < len(query) && query[i+1] == quote {
+				i++
+				continue
+			}
+			return i + 1
+		}
+	}
+	return len(query)
+}
+
+func skipLine(query string, start int) int {
+	if end := strings.IndexByte(query[start:], '\n'); end >= 0 {
+		return start + end
+	}
+	return len(query)
+}
+
+func skipBlockComment(query string, start int, nested bool) int {
+	depth := 0
+	for i := start; i+1 < len(query); i++ {
+		switch {
+		case query[i] == '/' && query[i+1] == '*':
+			if depth == 0 || nested {
+				depth++
+			}
+			i++
+		case query[i] == '*' && query[i+1] == '/':
+			depth--
+			i++
+			if depth == 0 {
+				return i + 1
+			}
+		}
+	}
+	return len(query)
+}
+
+// skipDollar returns the index past a Postgres dollar-quoted string ($$...$$,
+// $tag$...$tag$), or start+1 when query[start] does not open one.
+func skipDollar(query string, start int) int {
+	i := start + 1
+	for i < len(query) && (isWordStart(query[i]) || (i > start+1 && isDigit(query[i]))) {
+		i++
+	}
+	if i >= len(query) || query[i] != '$' {
+		return start + 1
+	}
+	tag := query[start : i+1]
+	if end := strings.Index(query[i+1:], tag); end >= 0 {
+		return i + 1 + end + len(tag)
+	}
+	return len(query)
+}
+
+// skipNumber returns the index past the numeric literal at query[start]:
+// 0x1F, 42, 4.2, .5, 1e10 or 1.5E-3.
+func skipNumber(query string, start int) int {
+	i := start
+	if query[i] == '0' && i+1 < len(query) && (query[i+1] == 'x' || query[i+1] == 'X') {
+		i += 2
+		for i < len(query) && isHexDigit(query[i]) {
+			i++
+		}
+		return i
+	}
+	for i < len(query) && (isDigit(query[i]) || query[i] == '.') {
+		i++
+	}
+	if i < len(query) && (query[i] == 'e' || query[i] == 'E') {
+		j := i + 1
+		if j < len(query) && (query[j] == '+' || query[j] == '-') {
+			j++
+		}
+		if j < len(query) && isDigit(query[j]) {
+			for i = j; i < len(query) && isDigit(query[i]); i++ {
+			}
+		}
+	}
+	return i
+}
+
+func isWordStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c >= 0x80
+}
+
+func isWordPart(c byte) bool {
+	return isWordStart(c) || isDigit(c) || c == '$'
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isHexDigit(c byte) bool {
+	return isDigit(c) || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
@@ -0,0 +1,18 @@
+package lag
+
+import (
+	"context"
+	"time"
+)
+
+type maxLagKey struct{}
+
+// WithMaxLag overrides Config.MaxLag for reads started with ctx.
+func WithMaxLag(ctx context.Context, maxLag time.Duration) context.Context {
+	return context.WithValue(ctx, maxLagKey{}, maxLag)
+}
+
+func MaxLagFromContext(ctx context.Context) (time.Duration, bool) {
+	maxLag, ok := ctx.Value(maxLagKey{}).(time.Duration)
+	return maxLag, ok
+}
@@ -0,0 +1,175 @@
+package lag
+
+import (
+	"context"
+	"github.com/XuanHieuHo/spread-db/constant"
+	"github.com/XuanHieuHo/spread-db/gormix"
+	"github.com/XuanHieuHo/spread-db/gormix/balancer"
+	"gorm.io/gorm"
+	"sync"
+	"time"
+)
+
+// Probe measures how far behind the primary the replica behind db is.
+type Probe func(ctx context.Context, db *gorm.DB) (time.Duration, error)
+
+// postgresLagQuery reports zero when everything received has been replayed, so an
+// idle primary does not make its replicas look like they are falling behind.
+// That only holds while the replica is streaming from the primary: with its WAL
+// receiver gone nothing new is received, so it also tells whether one is.
+const postgresLagQuery = `SELECT EXISTS (SELECT 1 FROM pg_stat_wal_receiver WHERE status = 'streaming'), ` +
+	`CASE WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0 ` +
+	`ELSE COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0) END`
+
+// PostgresProbe fails with constant.ErrReplicationStopped when the replica is
+// not streaming from the primary, since its lag cannot be told then.
+func PostgresProbe(ctx context.Context, db *gorm.DB) (time.Duration, error) {
+	var (
+		streaming bool
+		seconds   float64
+	)
+	if err := db.WithContext(ctx).Raw(postgresLagQuery).Row().Scan(&streaming, &seconds); err != nil {
+		return 0, err
+	}
+	if !streaming {
+		return 0, constant.ErrReplicationStopped
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+type Config struct {
+	// MaxLag is the largest lag a replica may have and still serve reads. Defaults to 5s.
+	MaxLag time.Duration
+	// Interval between two rounds of probes. Defaults to 1s.
+	Interval time.Duration
+	// Timeout of a single probe. Defaults to 2s.
+	Timeout time.Duration
+	// Probe defaults to PostgresProbe.
+	Probe Probe
+}
+
+func (c Config) withDefaults() Config {
+	if c.MaxLag <= 0 {
+		c.MaxLag = 5 * time.Second
+	}
+	if c.Interval <= 0 {
+		c.Interval = time.Second
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = 2 * time.Second
+	}
+	if c.Probe == nil {
+		c.Probe = PostgresProbe
+	}
+	return c
+}
+
+type Status struct {
+	Replica string
+	Lag     time.Duration
+	// Measured is false until the first successful probe and after a failed one.
+	Measured      bool
+	LastError     error
+	LastCheckedAt time.Time
+}
+
+type state struct {
+	replica *balancer.Replica
+
+	mu     sync.RWMutex
+	status Status
+}
+
+// Monitor probes replicas in the background and tells which of them are fresh enough to read from.
+type Monitor struct {
+	cfg    Config
+	states []*state
+	index  map[*balancer.Replica]*state
+
+	loop gormix.Loop
+}
+
+func NewMonitor(replicas []*balancer.Replica, cfg Config) *Monitor {
+	m := &Monitor{
+		cfg:   cfg.withDefaults(),
+		index: make(map[*balancer.Replica]*state, len(replicas)),
+	}
+	for _, replica := range replicas {
+		s := &state{replica: replica, status: Status{Replica: replica.Name}}
+		m.states = append(m.states, s)
+		m.index[replica] = s
+	}
+	return m
+}
+
+// Start runs a first round of probes immediately and then one every Interval until Stop.
+func (m *Monitor) Start() {
+	m.loop.Start(m.cfg.Interval, m.CheckNow)
+}
+
+func (m *Monitor) Stop() {
+	m.loop.Stop()
+}
+
+// CheckNow probes every replica once, concurrently, and records the lag.
+func (m *Monitor) CheckNow(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, s := range m.states {
+		wg.Add(1)
+		go func(s *state) {
+			defer wg.Done()
+			probeCtx, cancel := context.WithTimeout(ctx, m.cfg.Timeout)
+			lag, err := m.cfg.Probe(probeCtx, s.replica.DB)
+			cancel()
+			if ctx.Err() != nil {
+				return
+			}
+			m.record(s, lag, err)
+		}(s)
+	}
+	wg.Wait()
+}
+
+func (m *Monitor) record(s *state, lag time.Duration, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.status.LastError = err
+	s.status.LastCheckedAt = time.Now()
+	s.status.Measured = err == nil
+	if err == nil {
+		s.status.Lag = lag
+	}
+}
+
+// Acceptable reports whether replica is within the lag allowed for ctx: the
+// override set with WithMaxLag, or Config.MaxLag. Replicas that have not been
+// probed yet are accepted, replicas whose last probe failed are not.
+func (m *Monitor) Acceptable(ctx context.Context, replica *balancer.Replica) bool {
+	s, ok := m.index[replica]
+	if !ok {
+		return false
+	}
+	maxLag := m.cfg.MaxLag
+	if override, ok := MaxLagFromContext(ctx); ok {
+		maxLag = override
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.status.LastError != nil {
+		return false
+	}
+	return !s.status.Measured || s.status.Lag <= maxLag
+}
+
+// Status returns a snapshot of every replica's lag, in registration order.
+func (m *Monitor) Status() []Status {
+	statuses := make([]Status, 0, len(m.states))
+	for _, s := range m.states {
+		s.mu.RLock()
+		statuses = append(statuses, s.status)
+		s.mu.RUnlock()
+	}
+	return statuses
+}
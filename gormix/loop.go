@@ -0,0 +1,58 @@
+package gormix
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Loop runs a function in the background until stopped. The zero value is ready to use.
+type Loop struct {
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// Start calls tick immediately and then once every interval until Stop. tick is
+// passed a context that is cancelled by Stop. Start does nothing while the loop runs.
+func (l *Loop) Start(interval time.Duration, tick func(ctx context.Context)) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.cancel != nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	l.cancel = cancel
+	l.done = make(chan struct{})
+	go run(ctx, l.done, interval, tick)
+}
+
+// Stop cancels the running tick and waits for it to return.
+func (l *Loop) Stop() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.cancel == nil {
+		return
+	}
+
+	l.cancel()
+	<-l.done
+	l.cancel = nil
+	l.done = nil
+}
+
+func run(ctx context.Context, done chan struct{}, interval time.Duration, tick func(ctx context.Context)) {
+	defer close(done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		tick(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
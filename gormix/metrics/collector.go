@@ -0,0 +1,45 @@
+package metrics
+
+import (
+	"context"
+	"github.com/XuanHieuHo/spread-db/gormix"
+	"gorm.io/gorm"
+)
+
+type Pool struct {
+	Role    string
+	Replica string
+	DB      *gorm.DB
+}
+
+// Collector samples the connection pool stats of every pool in the background.
+type Collector struct {
+	cfg   Config
+	pools []Pool
+
+	loop gormix.Loop
+}
+
+func NewCollector(pools []Pool, cfg Config) *Collector {
+	return &Collector{cfg: cfg.withDefaults(), pools: pools}
+}
+
+// Start samples immediately and then once every Interval until Stop.
+func (c *Collector) Start() {
+	c.loop.Start(c.cfg.Interval, func(context.Context) { c.CollectNow() })
+}
+
+func (c *Collector) Stop() {
+	c.loop.Stop()
+}
+
+// CollectNow reports the current stats of every pool. Pools without a *sql.DB are skipped.
+func (c *Collector) CollectNow() {
+	for _, pool := range c.pools {
+		sqlDB, err := pool.DB.DB()
+		if err != nil {
+			continue
+		}
+		c.cfg.Recorder.ObservePool(pool.Role, pool.Replica, sqlDB.Stats())
+	}
+}
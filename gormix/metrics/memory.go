@@ -0,0 +1,131 @@
+package metrics
+
+import (
+	"database/sql"
+	"sync"
+	"time"
+)
+
+var DefaultBuckets = []time.Duration{
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+}
+
+// Histogram counts observations per bucket. Counts[i] holds the observations
+// up to Buckets[i]; the last entry holds the ones above every bucket.
+type Histogram struct {
+	Buckets []time.Duration
+	Counts  []int64
+	Sum     time.Duration
+	Count   int64
+}
+
+func (h *Histogram) observe(d time.Duration) {
+	i := 0
+	for i < len(h.Buckets) && d > h.Buckets[i] {
+		i++
+	}
+	h.Counts[i]++
+	h.Sum += d
+	h.Count++
+}
+
+type QueryStats struct {
+	Count        int64
+	Errors       int64
+	RowsAffected int64
+	Latency      Histogram
+}
+
+type poolKey struct {
+	role    string
+	replica string
+}
+
+// Memory is a Recorder that keeps everything in memory, for tests and debugging.
+type Memory struct {
+	buckets []time.Duration
+
+	mu      sync.Mutex
+	queries map[Labels]*QueryStats
+	pools   map[poolKey]sql.DBStats
+}
+
+// NewMemory returns an empty Memory recorder. Latencies use DefaultBuckets
+// unless buckets, in ascending order, are given.
+func NewMemory(buckets ...time.Duration) *Memory {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	return &Memory{
+		buckets: buckets,
+		queries: make(map[Labels]*QueryStats),
+		pools:   make(map[poolKey]sql.DBStats),
+	}
+}
+
+func (m *Memory) ObserveQuery(labels Labels, duration time.Duration, rowsAffected int64, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stats, ok := m.queries[labels]
+	if !ok {
+		stats = &QueryStats{Latency: Histogram{
+			Buckets: m.buckets,
+			Counts:  make([]int64, len(m.buckets)+1),
+		}}
+		m.queries[labels] = stats
+	}
+	stats.Count++
+	if err != nil {
+		stats.Errors++
+	}
+	if rowsAffected > 0 {
+		stats.RowsAffected += rowsAffected
+	}
+	stats.Latency.observe(duration)
+}
+
+func (m *Memory) ObservePool(role string, replica string, stats sql.DBStats) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pools[poolKey{role: role, replica: replica}] = stats
+}
+
+// Queries returns a snapshot of the stats recorded for every label set.
+func (m *Memory) Queries() map[Labels]QueryStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	snapshot := make(map[Labels]QueryStats, len(m.queries))
+	for labels, stats := range m.queries {
+		copied := *stats
+		copied.Latency.Counts = append([]int64(nil), stats.Latency.Counts...)
+		snapshot[labels] = copied
+	}
+	return snapshot
+}
+
+// Pool returns the last stats sampled for a pool.
+func (m *Memory) Pool(role string, replica string) (sql.DBStats, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stats, ok := m.pools[poolKey{role: role, replica: replica}]
+	return stats, ok
+}
+
+func (m *Memory) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.queries = make(map[Labels]*QueryStats)
+	m.pools = make(map[poolKey]sql.DBStats)
+}
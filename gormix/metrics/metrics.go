@@ -0,0 +1,33 @@
+package metrics
+
+import (
+	"database/sql"
+	"time"
+)
+
+type Labels struct {
+	Role      string
+	Replica   string
+	Table     string
+	Operation string
+}
+
+// Recorder receives measurements. Implementations must be safe for concurrent
+// use; adapt it to Prometheus or OpenTelemetry metrics to export them.
+type Recorder interface {
+	ObserveQuery(labels Labels, duration time.Duration, rowsAffected int64, err error)
+	ObservePool(role string, replica string, stats sql.DBStats)
+}
+
+type Config struct {
+	Recorder Recorder
+	// Interval between two samples of the connection pool stats. Defaults to 15s.
+	Interval time.Duration
+}
+
+func (c Config) withDefaults() Config {
+	if c.Interval <= 0 {
+		c.Interval = 15 * time.Second
+	}
+	return c
+}
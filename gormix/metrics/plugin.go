@@ -0,0 +1,53 @@
+package metrics
+
+import (
+	"errors"
+	"github.com/XuanHieuHo/spread-db/gormix"
+	"gorm.io/gorm"
+	"time"
+)
+
+const (
+	PluginName = "spreaddb:metrics"
+	startKey   = "spreaddb:metrics_start"
+)
+
+// Plugin is a gorm plugin that reports every statement run on a pool to a Recorder.
+type Plugin struct {
+	recorder Recorder
+	role     string
+	replica  string
+}
+
+func NewPlugin(recorder Recorder, role string, replica string) *Plugin {
+	return &Plugin{recorder: recorder, role: role, replica: replica}
+}
+
+func (p *Plugin) Name() string {
+	return PluginName
+}
+
+func (p *Plugin) Initialize(db *gorm.DB) error {
+	return gormix.RegisterAround(db, "spreaddb:metrics", p.start, p.end)
+}
+
+func (p *Plugin) start(_ string, db *gorm.DB) {
+	db.InstanceSet(startKey, time.Now())
+}
+
+func (p *Plugin) end(operation string, db *gorm.DB) {
+	value, ok := db.InstanceGet(startKey)
+	if !ok || db.DryRun {
+		return
+	}
+	err := db.Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		err = nil
+	}
+	p.recorder.ObserveQuery(Labels{
+		Role:      gormix.RoleOf(db, p.role),
+		Replica:   p.replica,
+		Table:     db.Statement.Table,
+		Operation: operation,
+	}, time.Since(value.(time.Time)), db.RowsAffected, err)
+}
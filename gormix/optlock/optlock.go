@@ -0,0 +1,121 @@
+package optlock
+
+import (
+	"github.com/XuanHieuHo/spread-db/constant"
+	"gorm.io/gorm"
+	"gorm.io/gorm/callbacks"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+	"reflect"
+)
+
+const (
+	PluginName = "spreaddb:optlock"
+	// TagName marks the version column of a model, e.g. `gorm:"column:version;version"`.
+	TagName = "VERSION"
+
+	stateKey = "spreaddb:optlock_state"
+)
+
+// Plugin adds optimistic locking to updates of models with a version column.
+// An update of a loaded record (non-zero primary key) only matches the row when
+// its version is unchanged and bumps the version by one; when no row matches,
+// the statement fails with constant.ErrStaleObject.
+type Plugin struct{}
+
+type state struct {
+	field *schema.Field
+	next  interface{}
+}
+
+func (Plugin) Name() string {
+	return PluginName
+}
+
+func (Plugin) Initialize(db *gorm.DB) error {
+	callback := db.Callback()
+	if err := callback.Update().After("gorm:before_update").Before("gorm:update").Register("spreaddb:optlock_check", checkVersion); err != nil {
+		return err
+	}
+	return callback.Update().After("gorm:update").Before("gorm:commit_or_rollback_transaction").Register("spreaddb:optlock_verify", verifyVersion)
+}
+
+// VersionField returns the field tagged as version column, or nil when s has none.
+func VersionField(s *schema.Schema) *schema.Field {
+	for _, field := range s.Fields {
+		if _, ok := field.TagSettings[TagName]; ok {
+			return field
+		}
+	}
+	return nil
+}
+
+func checkVersion(db *gorm.DB) {
+	stmt := db.Statement
+	if db.Error != nil || stmt.Schema == nil || stmt.SQL.Len() > 0 || stmt.ReflectValue.Kind() != reflect.Struct {
+		return
+	}
+	if _, ok := stmt.Clauses["SET"]; ok {
+		return
+	}
+	field := VersionField(stmt.Schema)
+	if field == nil {
+		return
+	}
+	for _, pf := range stmt.Schema.PrimaryFields {
+		if _, isZero := pf.ValueOf(stmt.Context, stmt.ReflectValue); isZero {
+			return
+		}
+	}
+	current, _ := field.ValueOf(stmt.Context, stmt.ReflectValue)
+	next, ok := increment(current)
+	if !ok {
+		return
+	}
+
+	set := callbacks.ConvertToAssignments(stmt)
+	if len(set) == 0 {
+		return
+	}
+	assignments := make(clause.Set, 0, len(set)+1)
+	for _, assignment := range set {
+		if assignment.Column.Name != field.DBName {
+			assignments = append(assignments, assignment)
+		}
+	}
+	assignments = append(assignments, clause.Assignment{Column: clause.Column{Name: field.DBName}, Value: next})
+	stmt.AddClause(assignments)
+	stmt.AddClause(clause.Where{Exprs: []clause.Expression{
+		clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: field.DBName}, Value: current},
+	}})
+	db.InstanceSet(stateKey, state{field: field, next: next})
+}
+
+func verifyVersion(db *gorm.DB) {
+	value, ok := db.InstanceGet(stateKey)
+	if !ok {
+		return
+	}
+	// the SET clause was built by checkVersion, so gorm:update leaves it in place
+	delete(db.Statement.Clauses, "SET")
+	if db.Error != nil || db.DryRun {
+		return
+	}
+	if db.RowsAffected == 0 {
+		db.AddError(constant.ErrStaleObject)
+		return
+	}
+	s := value.(state)
+	db.AddError(s.field.Set(db.Statement.Context, db.Statement.ReflectValue, s.next))
+}
+
+func increment(version interface{}) (interface{}, bool) {
+	v := reflect.ValueOf(version)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() + 1, true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return v.Uint() + 1, true
+	}
+	return nil, false
+}
@@ -0,0 +1,72 @@
+package outbox
+
+import (
+	"context"
+	"github.com/XuanHieuHo/spread-db/constant"
+	"github.com/XuanHieuHo/spread-db/gormix"
+	"gorm.io/gorm"
+	"time"
+)
+
+type Status string
+
+const (
+	StatusPending Status = "pending"
+	StatusSent    Status = "sent"
+	// StatusDead marks messages that failed Config.MaxAttempts times. The relay
+	// leaves them alone; set them back to StatusPending to try again.
+	StatusDead Status = "dead"
+)
+
+// Event is what callers enqueue; it is stored as a Message.
+type Event struct {
+	Topic   string
+	Key     string
+	Payload []byte
+}
+
+// Message is a row of the outbox table. Create the table with AutoMigrate(&Message{}).
+type Message struct {
+	ID          int64      `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	Topic       string     `gorm:"column:topic;type:varchar(255);not null" json:"topic"`
+	Key         string     `gorm:"column:key;type:varchar(255);not null" json:"key"`
+	Payload     []byte     `gorm:"column:payload;not null" json:"payload"`
+	Status      Status     `gorm:"column:status;type:varchar(16);not null;index:idx_outbox_messages_pending,priority:1" json:"status"`
+	Attempts    int        `gorm:"column:attempts;not null" json:"attempts"`
+	LastError   string     `gorm:"column:last_error;type:text;not null" json:"last_error"`
+	AvailableAt time.Time  `gorm:"column:available_at;not null;index:idx_outbox_messages_pending,priority:2" json:"available_at"`
+	CreatedAt   time.Time  `gorm:"column:created_at;not null" json:"created_at"`
+	SentAt      *time.Time `gorm:"column:sent_at" json:"sent_at"`
+}
+
+func (Message) TableName() string {
+	return "outbox_messages"
+}
+
+// Enqueue stores events in the outbox with the transaction db runs in, or the
+// one carried by ctx, so they are committed or rolled back together with the
+// change they describe. It fails with constant.ErrEnqueueOutsideTransaction
+// when there is no transaction.
+func Enqueue(ctx context.Context, db gormix.WriteOnlyDB, events ...Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+	db = db.WithContext(ctx)
+	if _, inTx := db.Statement().ConnPool.(gorm.TxCommitter); !inTx {
+		return constant.ErrEnqueueOutsideTransaction
+	}
+
+	now := time.Now()
+	messages := make([]Message, 0, len(events))
+	for _, event := range events {
+		messages = append(messages, Message{
+			Topic:       event.Topic,
+			Key:         event.Key,
+			Payload:     event.Payload,
+			Status:      StatusPending,
+			AvailableAt: now,
+			CreatedAt:   now,
+		})
+	}
+	return db.Create(&messages).Error()
+}
@@ -0,0 +1,237 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"github.com/XuanHieuHo/spread-db/constant"
+	"github.com/XuanHieuHo/spread-db/gormix"
+	"time"
+)
+
+// Publisher hands a message to the broker. Delivery is at least once: a message
+// is published again when marking it sent fails, or when its lease runs out
+// before that, so consumers should dedupe on Message.ID.
+type Publisher interface {
+	Publish(ctx context.Context, msg Message) error
+}
+
+type PublisherFunc func(ctx context.Context, msg Message) error
+
+func (f PublisherFunc) Publish(ctx context.Context, msg Message) error {
+	return f(ctx, msg)
+}
+
+type Config struct {
+	// BatchSize is the number of messages claimed per poll. Defaults to 100.
+	BatchSize int
+	// Interval between two polls when the last one did not fill a batch. Defaults to 1s.
+	Interval time.Duration
+	// MaxAttempts before a message is moved to StatusDead. Defaults to 10.
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry of a message. Defaults to 1s.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay between two attempts. Defaults to 5m.
+	MaxBackoff time.Duration
+	// PublishTimeout bounds a single Publish call. Defaults to 10s.
+	PublishTimeout time.Duration
+	// Lease is how long a claimed batch stays hidden from other polls while it is
+	// published. Defaults to BatchSize times PublishTimeout, which covers a batch
+	// whose every Publish times out.
+	Lease time.Duration
+	// OnError is called with the errors of background polls.
+	OnError func(err error)
+}
+
+func (c Config) withDefaults() Config {
+	if c.BatchSize <= 0 {
+		c.BatchSize = 100
+	}
+	if c.Interval <= 0 {
+		c.Interval = time.Second
+	}
+	if c.MaxAttempts <= 0 {
+		c.MaxAttempts = 10
+	}
+	if c.InitialBackoff <= 0 {
+		c.InitialBackoff = time.Second
+	}
+	if c.MaxBackoff <= 0 {
+		c.MaxBackoff = 5 * time.Minute
+	}
+	if c.PublishTimeout <= 0 {
+		c.PublishTimeout = 10 * time.Second
+	}
+	if c.Lease <= 0 {
+		c.Lease = time.Duration(c.BatchSize) * c.PublishTimeout
+	}
+	return c
+}
+
+// backoff returns the delay after the given failed attempt, doubling each time.
+func (c Config) backoff(attempt int) time.Duration {
+	delay := c.InitialBackoff
+	for i := 1; i < attempt && delay < c.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > c.MaxBackoff {
+		delay = c.MaxBackoff
+	}
+	return delay
+}
+
+// Relay polls the outbox and hands pending messages to a Publisher. A batch is
+// claimed with FOR UPDATE SKIP LOCKED in a short transaction that leases it for
+// Config.Lease by pushing back available_at, so several relays can share a
+// table. The messages are then published outside of it, and the outcome of
+// each is recorded on its own.
+type Relay struct {
+	db        gormix.WriteOnlyDB
+	publisher Publisher
+	cfg       Config
+
+	loop gormix.Loop
+}
+
+func NewRelay(db gormix.WriteOnlyDB, publisher Publisher, cfg Config) *Relay {
+	return &Relay{db: db, publisher: publisher, cfg: cfg.withDefaults()}
+}
+
+// Start polls immediately and then every Interval until Stop. A full batch is
+// followed by the next poll right away.
+func (r *Relay) Start() {
+	r.loop.Start(r.cfg.Interval, r.drain)
+}
+
+// Stop interrupts the poll in progress and waits for it. The outcome of a
+// finished Publish is still recorded, and the unpublished rest of the batch is
+// handed back without waiting for its lease to run out.
+func (r *Relay) Stop() {
+	r.loop.Stop()
+}
+
+// drain polls until a batch comes back short or the poll fails.
+func (r *Relay) drain(ctx context.Context) {
+	for ctx.Err() == nil {
+		n, err := r.RelayNow(ctx)
+		if err != nil && ctx.Err() == nil && r.cfg.OnError != nil {
+			r.cfg.OnError(err)
+		}
+		if err != nil || n < r.cfg.BatchSize {
+			return
+		}
+	}
+}
+
+// RelayNow claims one batch of due messages and publishes them in order. A
+// failure to record the outcome of one message does not stop the others; the
+// errors are returned together. It returns the number of messages claimed.
+func (r *Relay) RelayNow(ctx context.Context) (int, error) {
+	batch, err := r.claim(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	// outcomes are recorded even once ctx is cancelled, so that a Stop does not
+	// lead to publishing the same messages again
+	db := r.db.WithContext(context.WithoutCancel(ctx))
+	var errs []error
+	for i, msg := range batch {
+		err := ctx.Err()
+		if err == nil {
+			err = r.publish(ctx, msg)
+		}
+		if err != nil && ctx.Err() != nil {
+			// cancelled, not a verdict on the message
+			errs = append(errs, r.release(db, batch[i:]))
+			break
+		}
+		errs = append(errs, r.record(db, msg, err))
+	}
+	return len(batch), errors.Join(errs...)
+}
+
+// claim locks a batch of due messages and leases it in one transaction. The
+// lease expiry, stored in available_at, is what tells later updates whether
+// the messages are still held by this relay.
+func (r *Relay) claim(ctx context.Context) ([]Message, error) {
+	var batch []Message
+	err := r.db.WithContext(ctx).Transaction(func(tx gormix.WriteOnlyDB) error {
+		now := r.now()
+		err := tx.Where("status = ? AND available_at <= ?", StatusPending, now).
+			Order("id").
+			Limit(r.cfg.BatchSize).
+			LockForUpdate(gormix.LockOptions{SkipLocked: true}).
+			Find(&batch).Error()
+		if err != nil || len(batch) == 0 {
+			return err
+		}
+		// truncated so that it survives the column's precision and compares equal later
+		until := now.Add(r.cfg.Lease).Truncate(time.Millisecond)
+		if err := tx.Model(&Message{}).Where("id IN ?", ids(batch)).Update("available_at", until).Error(); err != nil {
+			return err
+		}
+		for i := range batch {
+			batch[i].AvailableAt = until
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return batch, nil
+}
+
+// release hands the rest of a batch back, unless its lease was lost meanwhile.
+func (r *Relay) release(db gormix.WriteOnlyDB, batch []Message) error {
+	return held(db, batch).Update("available_at", r.now()).Error()
+}
+
+// held scopes db to the messages of batch that are still pending under the
+// lease this relay took on them.
+func held(db gormix.WriteOnlyDB, batch []Message) gormix.WriteOnlyDB {
+	return db.Model(&Message{}).Where("id IN ? AND status = ? AND available_at = ?", ids(batch), StatusPending, batch[0].AvailableAt)
+}
+
+func ids(batch []Message) []int64 {
+	ids := make([]int64, 0, len(batch))
+	for _, msg := range batch {
+		ids = append(ids, msg.ID)
+	}
+	return ids
+}
+
+func (r *Relay) publish(ctx context.Context, msg Message) error {
+	ctx, cancel := context.WithTimeout(ctx, r.cfg.PublishTimeout)
+	defer cancel()
+	return r.publisher.Publish(ctx, msg)
+}
+
+// record stores the outcome of publishing msg, which failed unless err is nil.
+// It fails with constant.ErrLeaseLost when another relay has claimed msg since.
+func (r *Relay) record(db gormix.WriteOnlyDB, msg Message, err error) error {
+	attempts := msg.Attempts + 1
+	now := r.now()
+	updates := map[string]interface{}{"status": StatusSent, "attempts": attempts, "last_error": "", "sent_at": now}
+	if err != nil {
+		updates = map[string]interface{}{"attempts": attempts, "last_error": err.Error()}
+		if attempts >= r.cfg.MaxAttempts {
+			updates["status"] = StatusDead
+		} else {
+			updates["available_at"] = now.Add(r.cfg.backoff(attempts))
+		}
+	}
+
+	result := held(db, []Message{msg}).Updates(updates)
+	if err := result.Error(); err != nil {
+		return err
+	}
+	if result.Statement().RowsAffected == 0 {
+		return constant.ErrLeaseLost
+	}
+	return nil
+}
+
+// now reads the clock of the gorm.DB behind the relay, see gorm.Config.NowFunc.
+func (r *Relay) now() time.Time {
+	return r.db.Statement().DB.NowFunc()
+}
@@ -0,0 +1,56 @@
+package pagination
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/XuanHieuHo/spread-db/constant"
+	"reflect"
+)
+
+type position struct {
+	Values   []interface{}
+	Backward bool
+}
+
+type encodedCursor struct {
+	Values   []json.RawMessage `json:"v"`
+	Backward bool              `json:"b,omitempty"`
+}
+
+func encodeCursor(ctx context.Context, keys []key, row reflect.Value, backward bool) string {
+	c := encodedCursor{Backward: backward}
+	for _, k := range keys {
+		value, _ := k.field.ValueOf(ctx, row)
+		raw, err := json.Marshal(value)
+		if err != nil {
+			raw = []byte("null")
+		}
+		c.Values = append(c.Values, raw)
+	}
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor restores the cursor values with the Go types of their fields so
+// that they bind to the query exactly as the original column values would.
+func decodeCursor(cursor string, keys []key) (*position, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, constant.ErrInvalidCursor
+	}
+	var c encodedCursor
+	if err := json.Unmarshal(data, &c); err != nil || len(c.Values) != len(keys) {
+		return nil, constant.ErrInvalidCursor
+	}
+
+	p := &position{Backward: c.Backward, Values: make([]interface{}, 0, len(keys))}
+	for i, k := range keys {
+		value := reflect.New(k.field.FieldType)
+		if err := json.Unmarshal(c.Values[i], value.Interface()); err != nil {
+			return nil, constant.ErrInvalidCursor
+		}
+		p.Values = append(p.Values, value.Elem().Interface())
+	}
+	return p, nil
+}
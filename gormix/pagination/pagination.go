@@ -0,0 +1,162 @@
+package pagination
+
+import (
+	"context"
+	"github.com/XuanHieuHo/spread-db/constant"
+	"github.com/XuanHieuHo/spread-db/gormix"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+	"reflect"
+	"strings"
+)
+
+type Column struct {
+	Name string
+	Desc bool
+}
+
+func Asc(name string) Column {
+	return Column{Name: name}
+}
+
+func Desc(name string) Column {
+	return Column{Name: name, Desc: true}
+}
+
+// Page holds one page of results. NextCursor and PrevCursor are empty when
+// there is nothing further in that direction.
+type Page[T any] struct {
+	Items      []T
+	NextCursor string
+	PrevCursor string
+}
+
+type key struct {
+	column clause.Column
+	desc   bool
+	field  *schema.Field
+}
+
+// Paginate reads one page of T from db using keyset pagination on columns.
+// The primary key is appended as a tie-breaker unless it is already one of the
+// columns. Pass the NextCursor or PrevCursor of a previous page as cursor, or ""
+// for the first page. db must not be ordered already, and the columns must not
+// be nullable.
+func Paginate[T any](ctx context.Context, db gormix.ReadOnlyDB, cursor string, pageSize int, columns ...Column) (Page[T], error) {
+	page := Page[T]{Items: []T{}}
+	if pageSize <= 0 {
+		return page, constant.ErrInvalidPageSize
+	}
+
+	q := db.WithContext(ctx)
+	if err := q.Error(); err != nil {
+		return page, err
+	}
+	s, err := q.Parse(new(T))
+	if err != nil {
+		return page, err
+	}
+	keys, err := resolveKeys(s, columns)
+	if err != nil {
+		return page, err
+	}
+
+	var after *position
+	if cursor != "" {
+		if after, err = decodeCursor(cursor, keys); err != nil {
+			return page, err
+		}
+		q = q.Where(keysetCondition(keys, after))
+	}
+	backward := after != nil && after.Backward
+
+	items := []T{}
+	if err := q.Order(orderBy(keys, backward)).Limit(pageSize + 1).Find(&items).Error(); err != nil {
+		return page, err
+	}
+	hasMore := len(items) > pageSize
+	if hasMore {
+		items = items[:pageSize]
+	}
+	if backward {
+		for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+			items[i], items[j] = items[j], items[i]
+		}
+	}
+	page.Items = items
+	if len(items) == 0 {
+		return page, nil
+	}
+
+	first, last := &items[0], &items[len(items)-1]
+	if hasMore || backward {
+		page.NextCursor = encodeCursor(ctx, keys, reflect.ValueOf(last).Elem(), false)
+	}
+	if (backward && hasMore) || (!backward && after != nil) {
+		page.PrevCursor = encodeCursor(ctx, keys, reflect.ValueOf(first).Elem(), true)
+	}
+	return page, nil
+}
+
+// resolveKeys maps columns onto fields of s. Only the resolved field names are
+// ever written to SQL, never the caller's input.
+func resolveKeys(s *schema.Schema, columns []Column) ([]key, error) {
+	keys := make([]key, 0, len(columns)+1)
+	hasPrimaryKey := false
+	for _, column := range columns {
+		name, table := column.Name, ""
+		if i := strings.LastIndexByte(name, '.'); i >= 0 {
+			table, name = name[:i], name[i+1:]
+			if table != s.Table {
+				return nil, constant.ErrUnknownColumn
+			}
+		}
+		field := s.LookUpField(name)
+		if field == nil || field.DBName == "" {
+			return nil, constant.ErrUnknownColumn
+		}
+		if field == s.PrioritizedPrimaryField {
+			hasPrimaryKey = true
+		}
+		keys = append(keys, key{column: clause.Column{Table: table, Name: field.DBName}, desc: column.Desc, field: field})
+	}
+
+	if !hasPrimaryKey && s.PrioritizedPrimaryField != nil {
+		tieBreaker := key{column: clause.Column{Table: s.Table, Name: s.PrioritizedPrimaryField.DBName}, field: s.PrioritizedPrimaryField}
+		if len(columns) > 0 {
+			tieBreaker.desc = columns[len(columns)-1].Desc
+		}
+		keys = append(keys, tieBreaker)
+	}
+	if len(keys) == 0 {
+		return nil, constant.ErrUnknownColumn
+	}
+	return keys, nil
+}
+
+func orderBy(keys []key, backward bool) clause.OrderBy {
+	columns := make([]clause.OrderByColumn, 0, len(keys))
+	for _, k := range keys {
+		columns = append(columns, clause.OrderByColumn{Column: k.column, Desc: k.desc != backward})
+	}
+	return clause.OrderBy{Columns: columns}
+}
+
+// keysetCondition selects the rows strictly after p in the scan direction:
+// (c1 > v1) OR (c1 = v1 AND c2 > v2) OR ..., with < for descending columns.
+func keysetCondition(keys []key, p *position) clause.Expression {
+	branches := make([]clause.Expression, 0, len(keys))
+	for i := range keys {
+		exprs := make([]clause.Expression, 0, i+1)
+		for j := 0; j < i; j++ {
+			exprs = append(exprs, clause.Eq{Column: keys[j].column, Value: p.Values[j]})
+		}
+		if keys[i].desc != p.Backward {
+			exprs = append(exprs, clause.Lt{Column: keys[i].column, Value: p.Values[i]})
+		} else {
+			exprs = append(exprs, clause.Gt{Column: keys[i].column, Value: p.Values[i]})
+		}
+		branches = append(branches, clause.And(exprs...))
+	}
+	return clause.Or(branches...)
+}
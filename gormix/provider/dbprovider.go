@@ -1,8 +1,19 @@
 package provider
 
 import (
+	"context"
+	"database/sql"
+	"github.com/XuanHieuHo/spread-db/constant"
 	"github.com/XuanHieuHo/spread-db/gormix"
+	"github.com/XuanHieuHo/spread-db/gormix/balancer"
+	"github.com/XuanHieuHo/spread-db/gormix/consistency"
+	"github.com/XuanHieuHo/spread-db/gormix/health"
+	"github.com/XuanHieuHo/spread-db/gormix/lag"
+	"github.com/XuanHieuHo/spread-db/gormix/metrics"
+	"github.com/XuanHieuHo/spread-db/gormix/optlock"
 	"github.com/XuanHieuHo/spread-db/gormix/readonly"
+	"github.com/XuanHieuHo/spread-db/gormix/slowlog"
+	"github.com/XuanHieuHo/spread-db/gormix/tracing"
 	"github.com/XuanHieuHo/spread-db/gormix/writeonly"
 	"gorm.io/gorm"
 )
@@ -10,11 +21,298 @@ import (
 type DBProvider struct {
 	Read  gormix.ReadOnlyDB
 	Write gormix.WriteOnlyDB
+
+	primary    *gorm.DB
+	replicas   []*balancer.Replica
+	balancer   balancer.Balancer
+	health     *health.Checker
+	healthCfg  *health.Config
+	lag        *lag.Monitor
+	lagCfg     *lag.Config
+	tracker    *consistency.Tracker
+	guard      bool
+	metrics    *metrics.Collector
+	metricsCfg *metrics.Config
+	tracer     tracing.Tracer
+	slowLogCfg *slowlog.Config
+	optLock    bool
+}
+
+type Option func(p *DBProvider)
+
+// WithBalancer sets how replicas are chosen. Defaults to round-robin.
+func WithBalancer(b balancer.Balancer) Option {
+	return func(p *DBProvider) {
+		p.balancer = b
+	}
+}
+
+// WithHealthCheck pings replicas in the background and takes unhealthy ones out
+// of rotation. Reads fall back to the primary when no replica is healthy.
+func WithHealthCheck(cfg health.Config) Option {
+	return func(p *DBProvider) {
+		p.healthCfg = &cfg
+	}
+}
+
+// WithMaxReplicationLag probes replicas in the background and skips the ones
+// lagging more than cfg.MaxLag, or the limit set with lag.WithMaxLag on the read's context.
+func WithMaxReplicationLag(cfg lag.Config) Option {
+	return func(p *DBProvider) {
+		p.lagCfg = &cfg
+	}
+}
+
+// WithReadYourWrites keeps reads consistent with the writes made on the same
+// consistency.Session: for cfg.Window after a write, reads started with
+// WithContext(ctx) go to the primary, or wait for their replica to catch up
+// when cfg.WaitForReplica is set.
+func WithReadYourWrites(cfg consistency.Config) Option {
+	return func(p *DBProvider) {
+		p.tracker = consistency.NewTracker(cfg)
+	}
+}
+
+// WithReadOnlyGuard registers readonly.Guard on every replica, so writes issued
+// directly on a replica's *gorm.DB fail as well. The callbacks do not cover SQL
+// sent straight to the connection pool; for that, open each replica's pool with
+// sql.OpenDB(readonly.GuardConnector(...)) so the server itself refuses writes.
+// The provider cannot do it for you, as an opened *sql.DB does not expose its connector.
+func WithReadOnlyGuard() Option {
+	return func(p *DBProvider) {
+		p.guard = true
+	}
+}
+
+// WithMetrics reports every statement and the connection pool stats of the
+// primary and the replicas to cfg.Recorder. Reads that fall back to the primary
+// keep the read role.
+func WithMetrics(cfg metrics.Config) Option {
+	return func(p *DBProvider) {
+		p.metricsCfg = &cfg
+	}
+}
+
+// WithTracing wraps every statement on the primary and the replicas in a span
+// started from the statement's context.
+func WithTracing(tracer tracing.Tracer) Option {
+	return func(p *DBProvider) {
+		p.tracer = tracer
+	}
+}
+
+// WithSlowQueryLog reports statements on the primary and the replicas that run
+// longer than cfg.Threshold.
+func WithSlowQueryLog(cfg slowlog.Config) Option {
+	return func(p *DBProvider) {
+		p.slowLogCfg = &cfg
+	}
+}
+
+// WithOptimisticLocking registers optlock.Plugin on the primary: updates of
+// loaded records whose model tags a version column check and bump it, and fail
+// with constant.ErrStaleObject when the row changed in between.
+func WithOptimisticLocking() Option {
+	return func(p *DBProvider) {
+		p.optLock = true
+	}
 }
 
 func NewDBProvider(readDB *gorm.DB, writeDB *gorm.DB) *DBProvider {
-	return &DBProvider{
-		Read:  readonly.New(readDB),
-		Write: writeonly.New(writeDB),
+	p := &DBProvider{
+		Write:    writeonly.New(writeDB),
+		primary:  writeDB,
+		replicas: []*balancer.Replica{balancer.NewReplica("default", readDB, 1)},
+		balancer: balancer.NewRoundRobin(),
+	}
+	p.Read = readonly.NewWithResolver(readDB, p.resolveRead)
+	return p
+}
+
+// NewDBProviderWithReplicas spreads reads over replicas. Every chain started on
+// Read picks one replica through the balancer and stays on it.
+func NewDBProviderWithReplicas(writeDB *gorm.DB, replicas []*balancer.Replica, opts ...Option) (*DBProvider, error) {
+	if len(replicas) == 0 {
+		return nil, constant.ErrNoReplicas
+	}
+
+	p := &DBProvider{
+		Write:    writeonly.New(writeDB),
+		primary:  writeDB,
+		replicas: replicas,
+		balancer: balancer.NewRoundRobin(),
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	for _, replica := range replicas {
+		if err := replica.Track(); err != nil {
+			return nil, err
+		}
+		if p.guard {
+			if err := readonly.Guard(replica.DB); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if p.optLock {
+		if err := use(writeDB, optlock.Plugin{}); err != nil {
+			return nil, err
+		}
+	}
+	if p.tracker != nil {
+		if err := use(writeDB, p.tracker); err != nil {
+			return nil, err
+		}
+	}
+	if p.metricsCfg != nil {
+		if err := p.useMetrics(writeDB); err != nil {
+			return nil, err
+		}
+	}
+	if p.tracer != nil {
+		if err := p.useTracing(writeDB); err != nil {
+			return nil, err
+		}
+	}
+	if p.slowLogCfg != nil {
+		if err := p.useSlowQueryLog(writeDB); err != nil {
+			return nil, err
+		}
+	}
+	// primary only serves reads from here on, so label them as such
+	p.primary = writeDB.Set(gormix.RoleSetting, gormix.RoleRead).Session(&gorm.Session{})
+	p.Read = readonly.NewWithResolver(replicas[0].DB, p.resolveRead)
+	if p.healthCfg != nil {
+		p.health = health.NewChecker(replicas, *p.healthCfg)
+		p.health.Start()
+	}
+	if p.lagCfg != nil {
+		p.lag = lag.NewMonitor(replicas, *p.lagCfg)
+		p.lag.Start()
+	}
+	if p.metrics != nil {
+		p.metrics.Start()
+	}
+
+	return p, nil
+}
+
+// Health returns the current state of every replica, or nil when health checking is off.
+func (p *DBProvider) Health() []health.Status {
+	if p.health == nil {
+		return nil
+	}
+	return p.health.Status()
+}
+
+// ReplicationLag returns the last measured lag of every replica, or nil when lag probing is off.
+func (p *DBProvider) ReplicationLag() []lag.Status {
+	if p.lag == nil {
+		return nil
+	}
+	return p.lag.Status()
+}
+
+// Transaction runs fc in a write transaction and hands it a context carrying
+// that transaction, so any Write.WithContext(ctx) below joins it.
+func (p *DBProvider) Transaction(ctx context.Context, fc func(ctx context.Context) error, opts ...*sql.TxOptions) error {
+	return p.Write.WithContext(ctx).Transaction(func(tx gormix.WriteOnlyDB) error {
+		return fc(tx.Statement().Context)
+	}, opts...)
+}
+
+// Close stops the provider's background workers. It does not close the underlying connections.
+func (p *DBProvider) Close() {
+	if p.health != nil {
+		p.health.Stop()
+	}
+	if p.lag != nil {
+		p.lag.Stop()
+	}
+	if p.metrics != nil {
+		p.metrics.Stop()
+	}
+}
+
+func (p *DBProvider) useMetrics(writeDB *gorm.DB) error {
+	recorder := p.metricsCfg.Recorder
+	pools := []metrics.Pool{{Role: gormix.RoleWrite, Replica: "primary", DB: writeDB}}
+	if err := use(writeDB, metrics.NewPlugin(recorder, gormix.RoleWrite, "primary")); err != nil {
+		return err
+	}
+	for _, replica := range p.replicas {
+		if err := use(replica.DB, metrics.NewPlugin(recorder, gormix.RoleRead, replica.Name)); err != nil {
+			return err
+		}
+		pools = append(pools, metrics.Pool{Role: gormix.RoleRead, Replica: replica.Name, DB: replica.DB})
+	}
+	p.metrics = metrics.NewCollector(pools, *p.metricsCfg)
+	return nil
+}
+
+func (p *DBProvider) useTracing(writeDB *gorm.DB) error {
+	if err := use(writeDB, tracing.NewPlugin(p.tracer, gormix.RoleWrite, "primary")); err != nil {
+		return err
+	}
+	for _, replica := range p.replicas {
+		if err := use(replica.DB, tracing.NewPlugin(p.tracer, gormix.RoleRead, replica.Name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *DBProvider) useSlowQueryLog(writeDB *gorm.DB) error {
+	if err := use(writeDB, slowlog.NewPlugin(*p.slowLogCfg, gormix.RoleWrite, "primary")); err != nil {
+		return err
+	}
+	for _, replica := range p.replicas {
+		if err := use(replica.DB, slowlog.NewPlugin(*p.slowLogCfg, gormix.RoleRead, replica.Name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// use registers plugin on db unless a plugin of the same name already is: a
+// pool may be passed twice, and a NewDBProviderWithReplicas that failed half
+// way leaves its plugins behind for the retry.
+func use(db *gorm.DB, plugin gorm.Plugin) error {
+	if _, ok := db.Config.Plugins[plugin.Name()]; ok {
+		return nil
+	}
+	return db.Use(plugin)
+}
+
+func (p *DBProvider) resolveRead(ctx context.Context) *gorm.DB {
+	if gormix.PrimaryRequested(ctx) {
+		return p.primary
+	}
+	candidates := p.availableReplicas(ctx)
+	if len(candidates) == 0 {
+		return p.primary
+	}
+	replica := p.balancer.Pick(ctx, candidates).DB
+	if p.tracker != nil && !p.tracker.CanRead(ctx, replica) {
+		return p.primary
+	}
+	return replica
+}
+
+func (p *DBProvider) availableReplicas(ctx context.Context) []*balancer.Replica {
+	if p.health == nil && p.lag == nil {
+		return p.replicas
+	}
+	candidates := make([]*balancer.Replica, 0, len(p.replicas))
+	for _, replica := range p.replicas {
+		if p.health != nil && !p.health.Healthy(replica) {
+			continue
+		}
+		if p.lag != nil && !p.lag.Acceptable(ctx, replica) {
+			continue
+		}
+		candidates = append(candidates, replica)
 	}
+	return candidates
 }
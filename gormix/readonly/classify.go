@@ -0,0 +1,108 @@
+package readonly
+
+import (
+	"github.com/XuanHieuHo/spread-db/gormix/internal/sqllex"
+	"strings"
+)
+
+// readLeadingKeywords are the only statement heads accepted on the read pool.
+var readLeadingKeywords = map[string]bool{
+	"SELECT":   true,
+	"WITH":     true,
+	"VALUES":   true,
+	"TABLE":    true,
+	"SHOW":     true,
+	"EXPLAIN":  true,
+	"DESCRIBE": true,
+	"DESC":     true,
+}
+
+// writeKeywords may not appear anywhere in a read statement, which catches
+// data-modifying CTEs, SELECT ... INTO and EXPLAIN ANALYZE <write>.
+var writeKeywords = map[string]bool{
+	"INSERT":   true,
+	"UPDATE":   true,
+	"DELETE":   true,
+	"MERGE":    true,
+	"UPSERT":   true,
+	"TRUNCATE": true,
+	"DROP":     true,
+	"ALTER":    true,
+	"CREATE":   true,
+	"GRANT":    true,
+	"REVOKE":   true,
+	"COPY":     true,
+	"CALL":     true,
+	"INTO":     true,
+	"LOCK":     true,
+}
+
+// lockStrengths follow FOR in a locking clause (FOR UPDATE, FOR NO KEY UPDATE, FOR SHARE, FOR KEY SHARE).
+var lockStrengths = map[string]bool{
+	"UPDATE": true,
+	"NO":     true,
+	"SHARE":  true,
+	"KEY":    true,
+}
+
+type verdict int
+
+const (
+	verdictRead verdict = iota
+	verdictLock
+	verdictWrite
+)
+
+// IsWriteStatement reports whether query may modify data or take row locks when
+// run on dialect. Anything that is not clearly a read counts as a write.
+func IsWriteStatement(query string, dialect string) bool {
+	return classify(query, dialect) != verdictRead
+}
+
+// classify tells a plain read from a read that takes row locks and from anything else.
+func classify(query string, dialect string) verdict {
+	v := verdictRead
+	for _, stmt := range splitStatements(query, dialect) {
+		if len(stmt) == 0 {
+			continue
+		}
+		if !readLeadingKeywords[stmt[0]] {
+			return verdictWrite
+		}
+		for i := 0; i < len(stmt); i++ {
+			switch word := stmt[i]; {
+			case word == "FOR" && i+1 < len(stmt) && lockStrengths[stmt[i+1]]:
+				v = verdictLock
+				for i+1 < len(stmt) && lockStrengths[stmt[i+1]] {
+					i++
+				}
+			case word == "LOCK" && i+1 < len(stmt) && stmt[i+1] == "IN":
+				// MySQL's LOCK IN SHARE MODE
+				v = verdictLock
+			case writeKeywords[word]:
+				return verdictWrite
+			}
+		}
+	}
+	return v
+}
+
+// splitStatements returns the upper-cased bare words of every statement in query.
+// Literals, quoted identifiers and comments are skipped so that they cannot hide
+// or fake keywords.
+func splitStatements(query string, dialect string) [][]string {
+	var (
+		stmts [][]string
+		words []string
+	)
+	for _, token := range sqllex.Tokenize(query, dialect) {
+		switch {
+		case token.Kind == sqllex.Other && token.Text == ";":
+			stmts = append(stmts, words)
+			words = nil
+		case token.Kind == sqllex.Word:
+			words = append(words, strings.ToUpper(token.Text))
+		}
+	}
+	return append(stmts, words)
+}
@@ -0,0 +1,28 @@
+package readonly
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+)
+
+// errConnector fails every connection attempt with err. It is only used to
+// build a *sql.Row carrying an error, since sql.Row cannot be constructed
+// outside database/sql.
+type errConnector struct {
+	err error
+}
+
+func (c errConnector) Connect(context.Context) (driver.Conn, error) {
+	return nil, c.err
+}
+
+func (c errConnector) Driver() driver.Driver {
+	return nil
+}
+
+func errRow(err error) *sql.Row {
+	db := sql.OpenDB(errConnector{err: err})
+	defer db.Close()
+	return db.QueryRow("")
+}
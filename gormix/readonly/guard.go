@@ -0,0 +1,135 @@
+package readonly
+
+import (
+	"context"
+	"database/sql/driver"
+	"github.com/XuanHieuHo/spread-db/constant"
+	"gorm.io/gorm"
+)
+
+// readOnlySessions holds, per gorm dialector name, the statement that makes a new
+// session refuse writes on the server side.
+var readOnlySessions = map[string]string{
+	"postgres": "SET default_transaction_read_only = on",
+	"mysql":    "SET SESSION TRANSACTION READ ONLY",
+	"sqlite":   "PRAGMA query_only = ON",
+}
+
+type guardedConnector struct {
+	driver.Connector
+	statement string
+}
+
+// GuardConnector wraps base so that every connection it opens is switched to a
+// read-only session before use. Open the replica pool with sql.OpenDB on the
+// result, e.g. for pgx:
+//
+//	cfg, _ := pgx.ParseConfig(dsn)
+//	connector, err := readonly.GuardConnector(stdlib.GetConnector(*cfg), "postgres")
+//	replica, err := gorm.Open(postgres.New(postgres.Config{Conn: sql.OpenDB(connector)}), &gorm.Config{})
+func GuardConnector(base driver.Connector, dialect string) (driver.Connector, error) {
+	statement, ok := readOnlySessions[dialect]
+	if !ok {
+		return nil, constant.ErrUnsupportedDialect
+	}
+	return &guardedConnector{Connector: base, statement: statement}, nil
+}
+
+func (c *guardedConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := execSession(ctx, conn, c.statement); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+func execSession(ctx context.Context, conn driver.Conn, statement string) error {
+	if execer, ok := conn.(driver.ExecerContext); ok {
+		_, err := execer.ExecContext(ctx, statement, nil)
+		if err != driver.ErrSkip {
+			return err
+		}
+	}
+
+	stmt, err := conn.Prepare(statement)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	if execer, ok := stmt.(driver.StmtExecContext); ok {
+		_, err = execer.ExecContext(ctx, nil)
+		return err
+	}
+	_, err = stmt.Exec(nil)
+	return err
+}
+
+const guardName = "spreaddb:read_only_guard"
+
+// Guard registers callbacks on db that fail creates, updates, deletes and raw
+// write statements with constant.ErrWriteOperationOnReadDB, and queries with a
+// locking clause with constant.ErrLockOnReadDB, before they reach the driver.
+// Guarding db again is a no-op.
+func Guard(db *gorm.DB) error {
+	if _, ok := db.Config.Plugins[guardName]; ok {
+		return nil
+	}
+	return db.Use(guard{})
+}
+
+// guard is the plugin Guard registers, so that db remembers being guarded.
+type guard struct{}
+
+func (guard) Name() string {
+	return guardName
+}
+
+func (guard) Initialize(db *gorm.DB) error {
+	callback := db.Callback()
+	if err := callback.Create().Before("*").Register(guardName, rejectWrite); err != nil {
+		return err
+	}
+	if err := callback.Update().Before("*").Register(guardName, rejectWrite); err != nil {
+		return err
+	}
+	if err := callback.Delete().Before("*").Register(guardName, rejectWrite); err != nil {
+		return err
+	}
+	if err := callback.Raw().Before("*").Register(guardName, rejectWriteSQL); err != nil {
+		return err
+	}
+	if err := callback.Query().Before("*").Register(guardName, rejectLockingRead); err != nil {
+		return err
+	}
+	return callback.Row().Before("*").Register(guardName, rejectLockingRead)
+}
+
+func rejectWrite(db *gorm.DB) {
+	if db.Error != nil {
+		return
+	}
+	db.AddError(constant.ErrWriteOperationOnReadDB)
+}
+
+// rejectWriteSQL checks statements whose SQL was given up front (Raw, Exec);
+// SQL built by gorm's query callbacks is always a SELECT.
+func rejectWriteSQL(db *gorm.DB) {
+	if db.Error == nil && db.Statement.SQL.Len() > 0 {
+		if err := checkSQL(db.Statement.SQL.String(), db.Dialector.Name()); err != nil {
+			db.AddError(err)
+		}
+	}
+}
+
+// rejectLockingRead also catches row locks added with Clauses(clause.Locking{...}).
+func rejectLockingRead(db *gorm.DB) {
+	if _, ok := db.Statement.Clauses["FOR"]; ok && db.Error == nil {
+		db.AddError(constant.ErrLockOnReadDB)
+		return
+	}
+	rejectWriteSQL(db)
+}
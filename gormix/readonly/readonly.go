@@ -3,56 +3,86 @@ package readonly
 import (
 	"context"
 	"database/sql"
+	"github.com/XuanHieuHo/spread-db/constant"
 	"github.com/XuanHieuHo/spread-db/gormix"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
 )
 
 type readDB struct {
+	// db is the chain so far. On the root it only answers the getters, which
+	// must not pick a connection.
 	db *gorm.DB
+	// resolve picks the connection a new chain runs on; only set on the root.
+	resolve func(ctx context.Context) *gorm.DB
+}
+
+func (r readDB) conn() *gorm.DB {
+	if r.resolve != nil {
+		return r.resolve(context.Background())
+	}
+	return r.db
 }
 
 func (r readDB) WithContext(ctx context.Context) gormix.ReadOnlyDB {
-	return &readDB{db: r.db.WithContext(ctx)}
+	db := r.db
+	if r.resolve != nil {
+		db = r.resolve(ctx)
+	}
+	return &readDB{db: db.WithContext(ctx)}
 }
 
 func (r readDB) Table(name string) gormix.ReadOnlyDB {
-	return &readDB{db: r.db.Table(name)}
+	return &readDB{db: r.conn().Table(name)}
 }
 
 func (r readDB) Model(value interface{}) gormix.ReadOnlyDB {
-	return &readDB{db: r.db.Model(value)}
+	return &readDB{db: r.conn().Model(value)}
 }
 
 func (r readDB) Select(query interface{}, args ...interface{}) gormix.ReadOnlyDB {
-	return &readDB{db: r.db.Select(query, args...)}
+	return &readDB{db: r.conn().Select(query, args...)}
 }
 
 func (r readDB) Where(query interface{}, args ...interface{}) gormix.ReadOnlyDB {
-	return &readDB{db: r.db.Where(query, args...)}
+	return &readDB{db: r.conn().Where(unwrap(query), args...)}
+}
+
+func (r readDB) Or(query interface{}, args ...interface{}) gormix.ReadOnlyDB {
+	return &readDB{db: r.conn().Or(unwrap(query), args...)}
+}
+
+func (r readDB) Not(query interface{}, args ...interface{}) gormix.ReadOnlyDB {
+	return &readDB{db: r.conn().Not(unwrap(query), args...)}
 }
 
 func (r readDB) Joins(query string, args ...interface{}) gormix.ReadOnlyDB {
-	return &readDB{db: r.db.Joins(query, args...)}
+	return &readDB{db: r.conn().Joins(query, args...)}
+}
+
+func (r readDB) InnerJoins(query string, args ...interface{}) gormix.ReadOnlyDB {
+	return &readDB{db: r.conn().InnerJoins(query, args...)}
 }
 
 func (r readDB) Group(name string) gormix.ReadOnlyDB {
-	return &readDB{db: r.db.Group(name)}
+	return &readDB{db: r.conn().Group(name)}
 }
 
 func (r readDB) Having(query interface{}, args ...interface{}) gormix.ReadOnlyDB {
-	return &readDB{db: r.db.Having(query, args...)}
+	return &readDB{db: r.conn().Having(query, args...)}
 }
 
 func (r readDB) Order(value interface{}) gormix.ReadOnlyDB {
-	return &readDB{db: r.db.Order(value)}
+	return &readDB{db: r.conn().Order(value)}
 }
 
 func (r readDB) Limit(limit int) gormix.ReadOnlyDB {
-	return &readDB{db: r.db.Limit(limit)}
+	return &readDB{db: r.conn().Limit(limit)}
 }
 
 func (r readDB) Offset(offset int) gormix.ReadOnlyDB {
-	return &readDB{db: r.db.Offset(offset)}
+	return &readDB{db: r.conn().Offset(offset)}
 }
 
 func (r readDB) Scopes(funcs ...func(db gormix.ReadOnlyDB) gormix.ReadOnlyDB) gormix.ReadOnlyDB {
@@ -67,85 +97,159 @@ func (r readDB) Scopes(funcs ...func(db gormix.ReadOnlyDB) gormix.ReadOnlyDB) go
 			return gormDB
 		})
 	}
-	return &readDB{db: r.db.Scopes(gormScopes...)}
+	return &readDB{db: r.conn().Scopes(gormScopes...)}
 }
 
 func (r readDB) Unscoped() gormix.ReadOnlyDB {
-	return &readDB{db: r.db.Unscoped()}
+	return &readDB{db: r.conn().Unscoped()}
 }
 
+// Preload accepts func(gormix.ReadOnlyDB) gormix.ReadOnlyDB to customize the
+// preload query. gorm's func(*gorm.DB) *gorm.DB is rejected, as it would be
+// handed a writable connection.
 func (r readDB) Preload(query string, args ...interface{}) gormix.ReadOnlyDB {
-	return &readDB{db: r.db.Preload(query, args...)}
+	conds, err := preloadConds(args)
+	if err != nil {
+		tx := r.conn().Session(&gorm.Session{})
+		tx.AddError(err)
+		return &readDB{db: tx}
+	}
+	return &readDB{db: r.conn().Preload(query, conds...)}
 }
 
 func (r readDB) Distinct(args ...interface{}) gormix.ReadOnlyDB {
-	return &readDB{db: r.db.Distinct(args...)}
+	return &readDB{db: r.conn().Distinct(args...)}
 }
 
 func (r readDB) Omit(columns ...string) gormix.ReadOnlyDB {
-	return &readDB{db: r.db.Omit(columns...)}
+	return &readDB{db: r.conn().Omit(columns...)}
 }
 
 func (r readDB) Raw(sql string, values ...interface{}) gormix.ReadOnlyDB {
-	return &readDB{db: r.db.Raw(sql, values...)}
+	tx := r.conn().Raw(sql, values...)
+	if err := checkSQL(sql, tx.Dialector.Name()); err != nil {
+		tx.AddError(err)
+	}
+	return &readDB{db: tx}
+}
+
+func (r readDB) Clauses(conds ...clause.Expression) gormix.ReadOnlyDB {
+	for _, cond := range conds {
+		if err := checkClause(cond); err != nil {
+			tx := r.conn().Session(&gorm.Session{})
+			tx.AddError(err)
+			return &readDB{db: tx}
+		}
+	}
+	return &readDB{db: r.conn().Clauses(conds...)}
 }
 
 func (r readDB) Find(dest interface{}, conds ...interface{}) gormix.ReadOnlyDB {
-	return &readDB{db: r.db.Find(dest, conds...)}
+	return &readDB{db: r.conn().Find(dest, conds...)}
 }
 
 func (r readDB) First(dest interface{}, conds ...interface{}) gormix.ReadOnlyDB {
-	return &readDB{db: r.db.First(dest, conds...)}
+	return &readDB{db: r.conn().First(dest, conds...)}
+}
+
+// FirstOrInit finds the first record matching conds or initializes dest with
+// them. It never writes; Attrs and Assign are deliberately not exposed.
+func (r readDB) FirstOrInit(dest interface{}, conds ...interface{}) gormix.ReadOnlyDB {
+	return &readDB{db: r.conn().FirstOrInit(dest, conds...)}
 }
 
 func (r readDB) Last(dest interface{}, conds ...interface{}) gormix.ReadOnlyDB {
-	return &readDB{db: r.db.Last(dest, conds...)}
+	return &readDB{db: r.conn().Last(dest, conds...)}
 }
 
 func (r readDB) Take(dest interface{}, conds ...interface{}) gormix.ReadOnlyDB {
-	return &readDB{db: r.db.Take(dest, conds...)}
+	return &readDB{db: r.conn().Take(dest, conds...)}
 }
 
 func (r readDB) Scan(dest interface{}) gormix.ReadOnlyDB {
-	return &readDB{db: r.db.Scan(dest)}
+	return &readDB{db: r.conn().Scan(dest)}
 }
 
 func (r readDB) Pluck(column string, dest interface{}) gormix.ReadOnlyDB {
-	return &readDB{db: r.db.Pluck(column, dest)}
+	return &readDB{db: r.conn().Pluck(column, dest)}
 }
 
 func (r readDB) Count(count *int64) gormix.ReadOnlyDB {
-	return &readDB{db: r.db.Count(count)}
+	return &readDB{db: r.conn().Count(count)}
+}
+
+func (r readDB) FindInBatches(dest interface{}, batchSize int, fc func(tx gormix.ReadOnlyDB, batch int) error) gormix.ReadOnlyDB {
+	return &readDB{db: r.conn().FindInBatches(dest, batchSize, func(tx *gorm.DB, batch int) error {
+		return fc(&readDB{db: tx}, batch)
+	})}
 }
 
 func (r readDB) Row() *sql.Row {
-	return r.db.Row()
+	db := r.conn()
+	if db.Error != nil {
+		return errRow(db.Error)
+	}
+	return db.Row()
 }
 
 func (r readDB) Rows() (*sql.Rows, error) {
-	return r.db.Rows()
+	return r.conn().Rows()
+}
+
+func (r readDB) ScanRows(rows *sql.Rows, dest interface{}) error {
+	return r.db.ScanRows(rows, dest)
 }
 
 func (r readDB) Debug() gormix.ReadOnlyDB {
-	return &readDB{db: r.db.Debug()}
+	return &readDB{db: r.conn().Debug()}
 }
 
-func (r readDB) Statement() *gorm.Statement {
-	return r.db.Statement
+func (r readDB) Statement() gormix.StatementView {
+	return newStatementView(r.db.Statement)
+}
+
+func (r readDB) Parse(value interface{}) (*schema.Schema, error) {
+	stmt := &gorm.Statement{DB: r.db}
+	if err := stmt.Parse(value); err != nil {
+		return nil, err
+	}
+	return stmt.Schema, nil
 }
 
 func (r readDB) Error() error {
 	return r.db.Error
 }
 
-func (r readDB) Dialector() gorm.Dialector {
-	return r.db.Dialector
+func (r readDB) Dialector() gormix.Dialect {
+	return dialect{dialector: r.db.Dialector}
 }
 
 func (r readDB) Session(session *gorm.Session) gormix.ReadOnlyDB {
-	return &readDB{db: r.db.Session(session)}
+	if session == nil {
+		session = &gorm.Session{}
+	}
+	if enablesWrites(session) {
+		tx := r.conn().Session(&gorm.Session{})
+		tx.AddError(constant.ErrWriteSessionOnReadDB)
+		return &readDB{db: tx}
+	}
+	return &readDB{db: r.conn().Session(session)}
+}
+
+// Connection runs fc on a single connection of the pool.
+func (r readDB) Connection(fc func(tx gormix.ReadOnlyDB) error) error {
+	return r.conn().Connection(func(tx *gorm.DB) error {
+		return fc(&readDB{db: tx})
+	})
 }
 
 func New(db *gorm.DB) gormix.ReadOnlyDB {
 	return &readDB{db: db}
 }
+
+// NewWithResolver returns a ReadOnlyDB that calls resolve at the start of every
+// chain and keeps the returned connection for the rest of that chain. db
+// answers Statement, Error, Dialector and ScanRows on the root.
+func NewWithResolver(db *gorm.DB, resolve func(ctx context.Context) *gorm.DB) gormix.ReadOnlyDB {
+	return &readDB{db: db, resolve: resolve}
+}
@@ -0,0 +1,133 @@
+package readonly
+
+import (
+	"github.com/XuanHieuHo/spread-db/constant"
+	"github.com/XuanHieuHo/spread-db/gormix"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+func newStatementView(stmt *gorm.Statement) gormix.StatementView {
+	view := gormix.StatementView{
+		Context:  stmt.Context,
+		Table:    stmt.Table,
+		Schema:   stmt.Schema,
+		SQL:      stmt.SQL.String(),
+		Vars:     append([]interface{}(nil), stmt.Vars...),
+		Selects:  append([]string(nil), stmt.Selects...),
+		Omits:    append([]string(nil), stmt.Omits...),
+		Distinct: stmt.Distinct,
+		Unscoped: stmt.Unscoped,
+		Clauses:  make(map[string]clause.Clause, len(stmt.Clauses)),
+		Preloads: make(map[string][]interface{}, len(stmt.Preloads)),
+	}
+	for name, c := range stmt.Clauses {
+		view.Clauses[name] = c
+	}
+	for _, join := range stmt.Joins {
+		view.Joins = append(view.Joins, gormix.Join{
+			Name:     join.Name,
+			Conds:    join.Conds,
+			JoinType: join.JoinType,
+		})
+	}
+	for name, args := range stmt.Preloads {
+		view.Preloads[name] = append([]interface{}(nil), args...)
+	}
+	return view
+}
+
+// dialect hides the concrete gorm.Dialector, which usually holds the raw connection pool.
+type dialect struct {
+	dialector gorm.Dialector
+}
+
+func (d dialect) Name() string {
+	return d.dialector.Name()
+}
+
+func (d dialect) Explain(sql string, vars ...interface{}) string {
+	return d.dialector.Explain(sql, vars...)
+}
+
+// enablesWrites reports whether session sets options that only matter for writes.
+func enablesWrites(session *gorm.Session) bool {
+	if session == nil {
+		return false
+	}
+	return session.AllowGlobalUpdate ||
+		session.FullSaveAssociations ||
+		session.SkipDefaultTransaction ||
+		session.DisableNestedTransaction ||
+		session.CreateBatchSize != 0
+}
+
+// writeClauses are the clause names that only make sense in writes.
+var writeClauses = map[string]bool{
+	"RETURNING":   true,
+	"ON CONFLICT": true,
+	"INSERT":      true,
+	"UPDATE":      true,
+	"DELETE":      true,
+	"SET":         true,
+	"VALUES":      true,
+}
+
+// checkClause rejects clauses that lock rows or only make sense in writes.
+func checkClause(cond clause.Expression) error {
+	switch cond.(type) {
+	case clause.Locking, *clause.Locking:
+		return constant.ErrLockOnReadDB
+	case clause.Returning, *clause.Returning, clause.OnConflict, *clause.OnConflict:
+		return constant.ErrWriteClauseOnReadDB
+	}
+	if named, ok := cond.(clause.Interface); ok {
+		if named.Name() == "FOR" {
+			return constant.ErrLockOnReadDB
+		}
+		if writeClauses[named.Name()] {
+			return constant.ErrWriteClauseOnReadDB
+		}
+	}
+	return nil
+}
+
+// checkSQL rejects raw SQL that takes row locks or is not clearly a read.
+func checkSQL(sql string, dialect string) error {
+	switch classify(sql, dialect) {
+	case verdictLock:
+		return constant.ErrLockOnReadDB
+	case verdictWrite:
+		return constant.ErrWriteOperationOnReadDB
+	}
+	return nil
+}
+
+// unwrap lets a ReadOnlyDB chain be used as a group condition, as gorm does with *gorm.DB.
+func unwrap(query interface{}) interface{} {
+	if group, ok := query.(*readDB); ok {
+		return group.conn()
+	}
+	return query
+}
+
+// preloadConds turns ReadOnlyDB preload callbacks into the form gorm expects.
+func preloadConds(args []interface{}) ([]interface{}, error) {
+	conds := make([]interface{}, len(args))
+	for i, arg := range args {
+		switch fn := arg.(type) {
+		case func(gormix.ReadOnlyDB) gormix.ReadOnlyDB:
+			conds[i] = func(tx *gorm.DB) *gorm.DB {
+				if result, ok := fn(&readDB{db: tx}).(*readDB); ok {
+					return result.db
+				}
+				return tx
+			}
+		case func(*gorm.DB) *gorm.DB:
+			return nil, constant.ErrWriteOperationOnReadDB
+		default:
+			conds[i] = arg
+		}
+	}
+	return conds, nil
+}
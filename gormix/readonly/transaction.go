@@ -0,0 +1,73 @@
+package readonly
+
+import (
+	"database/sql"
+	"github.com/XuanHieuHo/spread-db/constant"
+	"github.com/XuanHieuHo/spread-db/gormix"
+	"gorm.io/gorm"
+)
+
+// isolationKey is the statement setting holding the isolation level of the
+// transaction a readDB runs in.
+const isolationKey = "spreaddb:tx_isolation"
+
+// Transaction runs fc in a read-only transaction pinned to a single replica
+// connection. opts may choose the isolation level but must keep ReadOnly set.
+// Nested calls join the outer transaction, and fail with
+// constant.ErrNestedIsolation when they ask for a stricter isolation level.
+func (r readDB) Transaction(fc func(tx gormix.ReadOnlyDB) error, opts ...*sql.TxOptions) error {
+	opt := &sql.TxOptions{ReadOnly: true}
+	if len(opts) > 0 && opts[0] != nil {
+		if !opts[0].ReadOnly {
+			return constant.ErrWriteTransactionOnReadDB
+		}
+		opt = opts[0]
+	}
+	return transaction(r.conn(), fc, opt, "")
+}
+
+// ReadSnapshot runs fc in a REPEATABLE READ, READ ONLY transaction so that every
+// query inside it sees the same snapshot. Nested in a transaction with a lower
+// isolation level it fails with constant.ErrNestedIsolation, as joining it
+// would not give a snapshot.
+func (r readDB) ReadSnapshot(fc func(tx gormix.ReadOnlyDB) error, opts ...gormix.SnapshotOptions) error {
+	var snapshot gormix.SnapshotOptions
+	if len(opts) > 0 {
+		snapshot = opts[0]
+	}
+
+	db := r.conn()
+	opt := &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true}
+	setup := ""
+	if snapshot.Deferrable {
+		if db.Dialector.Name() != "postgres" {
+			return constant.ErrUnsupportedDialect
+		}
+		opt.Isolation = sql.LevelSerializable
+		setup = "SET TRANSACTION DEFERRABLE"
+	}
+	return transaction(db, fc, opt, setup)
+}
+
+func transaction(db *gorm.DB, fc func(tx gormix.ReadOnlyDB) error, opt *sql.TxOptions, setup string) error {
+	if db.Error != nil {
+		return db.Error
+	}
+	if _, inTx := db.Statement.ConnPool.(gorm.TxCommitter); inTx {
+		outer, _ := db.Get(isolationKey)
+		if isolation, _ := outer.(sql.IsolationLevel); opt.Isolation > isolation {
+			return constant.ErrNestedIsolation
+		}
+		return fc(&readDB{db: db.Session(&gorm.Session{})})
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		tx = tx.Set(isolationKey, opt.Isolation)
+		if setup != "" {
+			// Executed on the pool directly: the read-only guard would reject SET.
+			if _, err := tx.Statement.ConnPool.ExecContext(tx.Statement.Context, setup); err != nil {
+				return err
+			}
+		}
+		return fc(&readDB{db: tx})
+	}, opt)
+}
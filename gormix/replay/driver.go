@@ -0,0 +1,352 @@
+package replay
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+)
+
+var errNoBase = errors.New("replay: recording needs a connector to the real database")
+
+type connector struct {
+	harness *Harness
+	role    string
+	base    driver.Connector
+}
+
+func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
+	if !c.harness.Recording() {
+		return &conn{harness: c.harness, role: c.role}, nil
+	}
+	if c.base == nil {
+		return nil, errNoBase
+	}
+	base, err := c.base.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &conn{harness: c.harness, role: c.role, base: base}, nil
+}
+
+func (c *connector) Driver() driver.Driver {
+	return replayDriver{}
+}
+
+type replayDriver struct{}
+
+func (replayDriver) Open(string) (driver.Conn, error) {
+	return nil, errors.New("replay: connections are only opened through a Harness")
+}
+
+// conn records the statements run on base, or serves them from the recording
+// when base is nil.
+type conn struct {
+	harness *Harness
+	role    string
+	base    driver.Conn
+}
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	return &stmt{conn: c, query: query}, nil
+}
+
+func (c *conn) Close() error {
+	if c.base == nil {
+		return nil
+	}
+	return c.base.Close()
+}
+
+func (c *conn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	args := []driver.NamedValue{
+		{Ordinal: 1, Value: int64(opts.Isolation)},
+		{Ordinal: 2, Value: opts.ReadOnly},
+	}
+	if c.base == nil {
+		entry, err := c.harness.take(c.role, "BEGIN", args)
+		if err != nil {
+			return nil, err
+		}
+		return &tx{conn: c}, entryError(entry)
+	}
+
+	var (
+		baseTx driver.Tx
+		err    error
+	)
+	if beginner, ok := c.base.(driver.ConnBeginTx); ok {
+		baseTx, err = beginner.BeginTx(ctx, opts)
+	} else {
+		baseTx, err = c.base.Begin()
+	}
+	c.harness.append(Entry{Role: c.role, SQL: "BEGIN", Args: encodeArgs(args), Error: errorString(err)})
+	if err != nil {
+		return nil, err
+	}
+	return &tx{conn: c, base: baseTx}, nil
+}
+
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if c.base == nil {
+		entry, err := c.harness.take(c.role, query, args)
+		if err != nil {
+			return nil, err
+		}
+		if err := entryError(entry); err != nil {
+			return nil, err
+		}
+		values := make([][]driver.Value, 0, len(entry.Rows))
+		for _, row := range entry.Rows {
+			decoded := make([]driver.Value, 0, len(row))
+			for _, value := range row {
+				v, err := value.decode()
+				if err != nil {
+					return nil, err
+				}
+				decoded = append(decoded, v)
+			}
+			values = append(values, decoded)
+		}
+		return &rows{columns: entry.Columns, values: values}, nil
+	}
+
+	entry := Entry{Role: c.role, SQL: query, Args: encodeArgs(args)}
+	columns, values, err := c.queryBase(ctx, query, args)
+	entry.Error = errorString(err)
+	if err == nil {
+		entry.Columns = columns
+		for _, row := range values {
+			encoded := make([]Value, 0, len(row))
+			for _, value := range row {
+				encoded = append(encoded, encodeValue(value))
+			}
+			entry.Rows = append(entry.Rows, encoded)
+		}
+	}
+	c.harness.append(entry)
+	if err != nil {
+		return nil, err
+	}
+	return &rows{columns: columns, values: values}, nil
+}
+
+// queryBase runs query on the real connection and reads the whole result.
+func (c *conn) queryBase(ctx context.Context, query string, args []driver.NamedValue) ([]string, [][]driver.Value, error) {
+	var (
+		baseRows driver.Rows
+		err      error
+	)
+	if queryer, ok := c.base.(driver.QueryerContext); ok {
+		baseRows, err = queryer.QueryContext(ctx, query, args)
+	}
+	if baseRows == nil && (err == nil || err == driver.ErrSkip) {
+		baseStmt, prepareErr := c.base.Prepare(query)
+		if prepareErr != nil {
+			return nil, nil, prepareErr
+		}
+		defer baseStmt.Close()
+		if queryer, ok := baseStmt.(driver.StmtQueryContext); ok {
+			baseRows, err = queryer.QueryContext(ctx, args)
+		} else {
+			baseRows, err = baseStmt.Query(namedToValues(args))
+		}
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	defer baseRows.Close()
+
+	columns := baseRows.Columns()
+	var values [][]driver.Value
+	for {
+		row := make([]driver.Value, len(columns))
+		if err := baseRows.Next(row); err != nil {
+			if err == io.EOF {
+				return columns, values, nil
+			}
+			return nil, nil, err
+		}
+		for i, value := range row {
+			// drivers may reuse the buffer behind []byte values
+			if b, ok := value.([]byte); ok {
+				row[i] = append([]byte(nil), b...)
+			}
+		}
+		values = append(values, row)
+	}
+}
+
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if c.base == nil {
+		entry, err := c.harness.take(c.role, query, args)
+		if err != nil {
+			return nil, err
+		}
+		if err := entryError(entry); err != nil {
+			return nil, err
+		}
+		return result{rowsAffected: entry.RowsAffected, lastInsertID: entry.LastInsertID}, nil
+	}
+
+	entry := Entry{Role: c.role, SQL: query, Args: encodeArgs(args)}
+	res, err := c.execBase(ctx, query, args)
+	entry.Error = errorString(err)
+	if err == nil {
+		entry.RowsAffected, _ = res.RowsAffected()
+		entry.LastInsertID, _ = res.LastInsertId()
+	}
+	c.harness.append(entry)
+	return res, err
+}
+
+func (c *conn) execBase(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if execer, ok := c.base.(driver.ExecerContext); ok {
+		res, err := execer.ExecContext(ctx, query, args)
+		if err != driver.ErrSkip {
+			return res, err
+		}
+	}
+	baseStmt, err := c.base.Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	defer baseStmt.Close()
+	if execer, ok := baseStmt.(driver.StmtExecContext); ok {
+		return execer.ExecContext(ctx, args)
+	}
+	return baseStmt.Exec(namedToValues(args))
+}
+
+func (c *conn) Ping(ctx context.Context) error {
+	if pinger, ok := c.base.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+// CheckNamedValue lets the real driver convert its own argument types while recording.
+func (c *conn) CheckNamedValue(value *driver.NamedValue) error {
+	if checker, ok := c.base.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(value)
+	}
+	return driver.ErrSkip
+}
+
+type tx struct {
+	conn *conn
+	base driver.Tx
+}
+
+func (t *tx) Commit() error {
+	return t.finish("COMMIT", func() error { return t.base.Commit() })
+}
+
+func (t *tx) Rollback() error {
+	return t.finish("ROLLBACK", func() error { return t.base.Rollback() })
+}
+
+func (t *tx) finish(statement string, run func() error) error {
+	if t.base == nil {
+		entry, err := t.conn.harness.take(t.conn.role, statement, nil)
+		if err != nil {
+			return err
+		}
+		return entryError(entry)
+	}
+	err := run()
+	t.conn.harness.append(Entry{Role: t.conn.role, SQL: statement, Error: errorString(err)})
+	return err
+}
+
+type stmt struct {
+	conn  *conn
+	query string
+}
+
+func (s *stmt) Close() error {
+	return nil
+}
+
+func (s *stmt) NumInput() int {
+	return -1
+}
+
+func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.conn.ExecContext(context.Background(), s.query, valuesToNamed(args))
+}
+
+func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.conn.QueryContext(context.Background(), s.query, valuesToNamed(args))
+}
+
+func (s *stmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	return s.conn.ExecContext(ctx, s.query, args)
+}
+
+func (s *stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	return s.conn.QueryContext(ctx, s.query, args)
+}
+
+type rows struct {
+	columns []string
+	values  [][]driver.Value
+	next    int
+}
+
+func (r *rows) Columns() []string {
+	return r.columns
+}
+
+func (r *rows) Close() error {
+	return nil
+}
+
+func (r *rows) Next(dest []driver.Value) error {
+	if r.next >= len(r.values) {
+		return io.EOF
+	}
+	copy(dest, r.values[r.next])
+	r.next++
+	return nil
+}
+
+type result struct {
+	rowsAffected int64
+	lastInsertID int64
+}
+
+func (r result) LastInsertId() (int64, error) {
+	return r.lastInsertID, nil
+}
+
+func (r result) RowsAffected() (int64, error) {
+	return r.rowsAffected, nil
+}
+
+func namedToValues(args []driver.NamedValue) []driver.Value {
+	values := make([]driver.Value, 0, len(args))
+	for _, arg := range args {
+		values = append(values, arg.Value)
+	}
+	return values
+}
+
+func valuesToNamed(args []driver.Value) []driver.NamedValue {
+	named := make([]driver.NamedValue, 0, len(args))
+	for i, arg := range args {
+		named = append(named, driver.NamedValue{Ordinal: i + 1, Value: arg})
+	}
+	return named
+}
+
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
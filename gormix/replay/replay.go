@@ -0,0 +1,217 @@
+// Package replay records the statements a test issues through a DBProvider to
+// a golden file and serves them back without a database on later runs.
+//
+// Run the tests with -spreaddb.record against a real database to (re)write the
+// golden files; without it every statement must match the recording exactly.
+package replay
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"flag"
+	"github.com/XuanHieuHo/spread-db/gormix"
+	"github.com/XuanHieuHo/spread-db/gormix/provider"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+var record = flag.Bool("spreaddb.record", false, "record statements to golden files instead of replaying them")
+
+var ErrDrift = errors.New("replay: statement does not match the recording")
+
+type Mode int
+
+const (
+	// ModeAuto records when the -spreaddb.record flag is set and replays otherwise.
+	ModeAuto Mode = iota
+	ModeRecord
+	ModeReplay
+)
+
+// Entry is one recorded statement and its outcome.
+type Entry struct {
+	Role         string    `json:"role"`
+	SQL          string    `json:"sql"`
+	Args         []Value   `json:"args,omitempty"`
+	Columns      []string  `json:"columns,omitempty"`
+	Rows         [][]Value `json:"rows,omitempty"`
+	RowsAffected int64     `json:"rows_affected,omitempty"`
+	LastInsertID int64     `json:"last_insert_id,omitempty"`
+	Error        string    `json:"error,omitempty"`
+}
+
+type Config struct {
+	Mode Mode
+	// Dialector opens gorm on top of a pool. Defaults to postgres.
+	Dialector func(conn gorm.ConnPool) gorm.Dialector
+	// Matchers loosen the comparison of arguments while replaying: an argument
+	// that differs from the recorded one still matches if one of them accepts
+	// it. Use AnyTime for timestamps gorm takes from the clock, such as
+	// CreatedAt, UpdatedAt and soft-delete DeletedAt.
+	Matchers []Matcher
+}
+
+// Matcher reports whether an issued argument may stand in for the recorded one.
+type Matcher func(recorded Value, issued Value) bool
+
+// AnyTime matches any time argument against any recorded time.
+func AnyTime(recorded Value, issued Value) bool {
+	return recorded.Type == "time" && issued.Type == "time"
+}
+
+// Harness records to or replays from the golden file at path. Statements of
+// every pool opened through it share one ordered recording.
+type Harness struct {
+	t    testing.TB
+	path string
+	cfg  Config
+
+	mu      sync.Mutex
+	entries []Entry
+	next    int
+}
+
+func New(t testing.TB, path string, cfg Config) *Harness {
+	t.Helper()
+
+	if cfg.Mode == ModeAuto {
+		cfg.Mode = ModeReplay
+		if *record {
+			cfg.Mode = ModeRecord
+		}
+	}
+	if cfg.Dialector == nil {
+		cfg.Dialector = func(conn gorm.ConnPool) gorm.Dialector {
+			return postgres.New(postgres.Config{Conn: conn})
+		}
+	}
+
+	h := &Harness{t: t, path: path, cfg: cfg}
+	if cfg.Mode == ModeReplay {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("replay: read golden file: %v", err)
+		}
+		if err := json.Unmarshal(data, &h.entries); err != nil {
+			t.Fatalf("replay: decode golden file %s: %v", path, err)
+		}
+	}
+	t.Cleanup(h.finish)
+	return h
+}
+
+func (h *Harness) Recording() bool {
+	return h.cfg.Mode == ModeRecord
+}
+
+// Open returns a gorm pool for role. base connects to the real database and is
+// only used while recording; it may be nil when replaying.
+func (h *Harness) Open(role string, base driver.Connector) *gorm.DB {
+	h.t.Helper()
+
+	sqlDB := sql.OpenDB(&connector{harness: h, role: role, base: base})
+	h.t.Cleanup(func() {
+		sqlDB.Close()
+	})
+	db, err := gorm.Open(h.cfg.Dialector(sqlDB), &gorm.Config{Logger: logger.Discard, DisableAutomaticPing: true})
+	if err != nil {
+		h.t.Fatalf("replay: open %s pool: %v", role, err)
+	}
+	return db
+}
+
+// DBProvider returns a provider whose read and write pools go through the harness.
+func (h *Harness) DBProvider(read driver.Connector, write driver.Connector) *provider.DBProvider {
+	h.t.Helper()
+	return provider.NewDBProvider(h.Open(gormix.RoleRead, read), h.Open(gormix.RoleWrite, write))
+}
+
+func (h *Harness) append(entry Entry) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.entries = append(h.entries, entry)
+}
+
+// take returns the next recorded entry if it matches the issued statement.
+func (h *Harness) take(role string, query string, args []driver.NamedValue) (Entry, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	issued := Entry{Role: role, SQL: query, Args: encodeArgs(args)}
+	if h.next >= len(h.entries) {
+		h.t.Errorf("replay: unexpected %s statement %q %v: the recording has only %d", role, query, issued.Args, len(h.entries))
+		return Entry{}, ErrDrift
+	}
+	recorded := h.entries[h.next]
+	if recorded.Role != issued.Role || recorded.SQL != issued.SQL || !h.matchArgs(recorded.Args, issued.Args) {
+		h.t.Errorf("replay: statement %d drifted\n got: %s %q %v\nwant: %s %q %v",
+			h.next, issued.Role, issued.SQL, issued.Args, recorded.Role, recorded.SQL, recorded.Args)
+		return Entry{}, ErrDrift
+	}
+	h.next++
+	return recorded, nil
+}
+
+func (h *Harness) matchArgs(recorded []Value, issued []Value) bool {
+	if len(recorded) != len(issued) {
+		return false
+	}
+	for i := range recorded {
+		if !h.matchArg(recorded[i], issued[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (h *Harness) matchArg(recorded Value, issued Value) bool {
+	if recorded == issued {
+		return true
+	}
+	for _, match := range h.cfg.Matchers {
+		if match(recorded, issued) {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *Harness) finish() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.cfg.Mode == ModeReplay {
+		if h.next < len(h.entries) {
+			h.t.Errorf("replay: %d recorded statements were not issued, next: %s %q",
+				len(h.entries)-h.next, h.entries[h.next].Role, h.entries[h.next].SQL)
+		}
+		return
+	}
+
+	data, err := json.MarshalIndent(h.entries, "", "  ")
+	if err != nil {
+		h.t.Errorf("replay: encode recording: %v", err)
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(h.path), 0o755); err != nil {
+		h.t.Errorf("replay: write golden file: %v", err)
+		return
+	}
+	if err := os.WriteFile(h.path, append(data, '\n'), 0o644); err != nil {
+		h.t.Errorf("replay: write golden file: %v", err)
+	}
+}
+
+func entryError(entry Entry) error {
+	if entry.Error == "" {
+		return nil
+	}
+	return errors.New(entry.Error)
+}
@@ -0,0 +1,67 @@
+package replay
+
+import (
+	"database/sql/driver"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Value is a driver.Value in a form that survives a round trip through JSON.
+type Value struct {
+	Type  string `json:"type"`
+	Value string `json:"value,omitempty"`
+}
+
+func encodeValue(v driver.Value) Value {
+	switch v := v.(type) {
+	case nil:
+		return Value{Type: "null"}
+	case int64:
+		return Value{Type: "int64", Value: strconv.FormatInt(v, 10)}
+	case float64:
+		return Value{Type: "float64", Value: strconv.FormatFloat(v, 'g', -1, 64)}
+	case bool:
+		return Value{Type: "bool", Value: strconv.FormatBool(v)}
+	case []byte:
+		return Value{Type: "bytes", Value: base64.StdEncoding.EncodeToString(v)}
+	case string:
+		return Value{Type: "string", Value: v}
+	case time.Time:
+		return Value{Type: "time", Value: v.Format(time.RFC3339Nano)}
+	default:
+		return Value{Type: "string", Value: fmt.Sprint(v)}
+	}
+}
+
+func (v Value) decode() (driver.Value, error) {
+	switch v.Type {
+	case "null":
+		return nil, nil
+	case "int64":
+		return strconv.ParseInt(v.Value, 10, 64)
+	case "float64":
+		return strconv.ParseFloat(v.Value, 64)
+	case "bool":
+		return strconv.ParseBool(v.Value)
+	case "bytes":
+		return base64.StdEncoding.DecodeString(v.Value)
+	case "string":
+		return v.Value, nil
+	case "time":
+		return time.Parse(time.RFC3339Nano, v.Value)
+	}
+	return nil, fmt.Errorf("replay: unknown value type %q", v.Type)
+}
+
+func encodeArgs(args []driver.NamedValue) []Value {
+	if len(args) == 0 {
+		return nil
+	}
+	values := make([]Value, 0, len(args))
+	for _, arg := range args {
+		values = append(values, encodeValue(arg.Value))
+	}
+	return values
+}
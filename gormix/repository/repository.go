@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"context"
+	"github.com/XuanHieuHo/spread-db/gormix"
+	"github.com/XuanHieuHo/spread-db/gormix/provider"
+	"gorm.io/gorm/clause"
+)
+
+// Filter narrows a read, e.g. repository.Where("age > ?", 20). Any ReadOnlyDB scope is a Filter.
+type Filter = func(db gormix.ReadOnlyDB) gormix.ReadOnlyDB
+
+func Where(query interface{}, args ...interface{}) Filter {
+	return func(db gormix.ReadOnlyDB) gormix.ReadOnlyDB {
+		return db.Where(query, args...)
+	}
+}
+
+func Order(value interface{}) Filter {
+	return func(db gormix.ReadOnlyDB) gormix.ReadOnlyDB {
+		return db.Order(value)
+	}
+}
+
+func Limit(limit int) Filter {
+	return func(db gormix.ReadOnlyDB) gormix.ReadOnlyDB {
+		return db.Limit(limit)
+	}
+}
+
+func Offset(offset int) Filter {
+	return func(db gormix.ReadOnlyDB) gormix.ReadOnlyDB {
+		return db.Offset(offset)
+	}
+}
+
+// Repository gives typed CRUD access to the model T. Reads go through
+// DBProvider.Read and writes through DBProvider.Write.
+type Repository[T any] struct {
+	db *provider.DBProvider
+}
+
+func New[T any](db *provider.DBProvider) *Repository[T] {
+	return &Repository[T]{db: db}
+}
+
+func (r *Repository[T]) read(ctx context.Context) gormix.ReadOnlyDB {
+	return r.db.Read.WithContext(ctx).Model(new(T))
+}
+
+// byID matches the primary key against id as a bound value; passing id to gorm
+// as a condition would run a string id as SQL.
+func byID(id interface{}) clause.Expression {
+	return clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: clause.PrimaryKey}, Value: id}
+}
+
+// Get returns the entity with the given primary key, or gorm.ErrRecordNotFound.
+func (r *Repository[T]) Get(ctx context.Context, id interface{}) (T, error) {
+	var entity T
+	err := r.read(ctx).Where(byID(id)).Take(&entity).Error()
+	return entity, err
+}
+
+func (r *Repository[T]) List(ctx context.Context, filters ...Filter) ([]T, error) {
+	entities := []T{}
+	err := r.read(ctx).Scopes(filters...).Find(&entities).Error()
+	return entities, err
+}
+
+func (r *Repository[T]) Exists(ctx context.Context, filters ...Filter) (bool, error) {
+	var found []int
+	err := r.read(ctx).Scopes(filters...).Select("1").Limit(1).Scan(&found).Error()
+	return len(found) > 0, err
+}
+
+func (r *Repository[T]) Count(ctx context.Context, filters ...Filter) (int64, error) {
+	var count int64
+	err := r.read(ctx).Scopes(filters...).Count(&count).Error()
+	return count, err
+}
+
+// Create inserts entity and returns it with the fields filled in by the database.
+func (r *Repository[T]) Create(ctx context.Context, entity T) (T, error) {
+	err := r.db.Write.WithContext(ctx).Create(&entity).Error()
+	return entity, err
+}
+
+// Update writes the non-zero fields of entity, matched on its primary key.
+func (r *Repository[T]) Update(ctx context.Context, entity T) (T, error) {
+	err := r.db.Write.WithContext(ctx).Model(&entity).Updates(&entity).Error()
+	return entity, err
+}
+
+func (r *Repository[T]) Delete(ctx context.Context, id interface{}) error {
+	return r.db.Write.WithContext(ctx).Where(byID(id)).Delete(new(T)).Error()
+}
@@ -0,0 +1,56 @@
+package retry
+
+import (
+	"errors"
+	"strings"
+)
+
+// Classifiers holds the default Classifier per gorm dialector name. Register
+// one here to support another dialect.
+var Classifiers = map[string]Classifier{
+	"postgres": IsPostgresRetryable,
+	"mysql":    IsMySQLRetryable,
+	"sqlite":   IsSQLiteRetryable,
+}
+
+// ClassifierFor returns the classifier registered for dialect, or one that
+// never retries.
+func ClassifierFor(dialect string) Classifier {
+	if classifier, ok := Classifiers[dialect]; ok {
+		return classifier
+	}
+	return func(error) bool {
+		return false
+	}
+}
+
+// IsPostgresRetryable matches serialization_failure (40001) and
+// deadlock_detected (40P01) from any driver exposing SQLState, such as pgx and lib/pq.
+func IsPostgresRetryable(err error) bool {
+	var stateErr interface{ SQLState() string }
+	if !errors.As(err, &stateErr) {
+		return false
+	}
+	switch stateErr.SQLState() {
+	case "40001", "40P01":
+		return true
+	}
+	return false
+}
+
+// IsMySQLRetryable matches deadlocks (1213) and lock wait timeouts (1205).
+func IsMySQLRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	msg := err.Error()
+	return strings.Contains(msg, "Error 1213") || strings.Contains(msg, "Error 1205")
+}
+
+func IsSQLiteRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	msg := err.Error()
+	return strings.Contains(msg, "database is locked") || strings.Contains(msg, "SQLITE_BUSY")
+}
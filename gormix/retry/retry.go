@@ -0,0 +1,93 @@
+package retry
+
+import (
+	"context"
+	"database/sql"
+	"github.com/XuanHieuHo/spread-db/gormix"
+	"gorm.io/gorm"
+	"math/rand/v2"
+	"time"
+)
+
+// Classifier reports whether a failed transaction may succeed if run again.
+type Classifier func(err error) bool
+
+type Policy struct {
+	// MaxAttempts counts the first run. Defaults to 3.
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry. Defaults to 10ms.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay between two attempts. Defaults to 1s.
+	MaxBackoff time.Duration
+	// Multiplier grows the delay after each retry. Defaults to 2.
+	Multiplier float64
+	// IsRetryable overrides the classifier registered for the database's dialect.
+	IsRetryable Classifier
+	// OnRetry is called before sleeping ahead of each retry.
+	OnRetry func(ctx context.Context, attempt int, err error, delay time.Duration)
+}
+
+func (p Policy) withDefaults() Policy {
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = 3
+	}
+	if p.InitialBackoff <= 0 {
+		p.InitialBackoff = 10 * time.Millisecond
+	}
+	if p.MaxBackoff <= 0 {
+		p.MaxBackoff = time.Second
+	}
+	if p.Multiplier < 1 {
+		p.Multiplier = 2
+	}
+	return p
+}
+
+// backoff returns the delay before the given retry, with the upper half jittered.
+func (p Policy) backoff(retry int) time.Duration {
+	delay := float64(p.InitialBackoff)
+	for i := 1; i < retry; i++ {
+		delay *= p.Multiplier
+	}
+	if delay > float64(p.MaxBackoff) {
+		delay = float64(p.MaxBackoff)
+	}
+	half := time.Duration(delay / 2)
+	return half + rand.N(half+1)
+}
+
+// Transaction runs fc in a transaction on db and runs it again, in a fresh
+// transaction, while it fails with an error the policy considers retryable.
+// fc must be safe to repeat. Inside an existing transaction there is nothing to
+// retry on its own, so fc runs once in a nested transaction.
+func Transaction(ctx context.Context, db gormix.WriteOnlyDB, policy Policy, fc func(tx gormix.WriteOnlyDB) error, opts ...*sql.TxOptions) error {
+	db = db.WithContext(ctx)
+	if _, inTx := db.Statement().ConnPool.(gorm.TxCommitter); inTx {
+		return db.Transaction(fc, opts...)
+	}
+
+	policy = policy.withDefaults()
+	isRetryable := policy.IsRetryable
+	if isRetryable == nil {
+		isRetryable = ClassifierFor(db.Dialector().Name())
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := db.Transaction(fc, opts...)
+		if err == nil || attempt >= policy.MaxAttempts || !isRetryable(err) {
+			return err
+		}
+
+		delay := policy.backoff(attempt)
+		if policy.OnRetry != nil {
+			policy.OnRetry(ctx, attempt, err, delay)
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
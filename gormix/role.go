@@ -0,0 +1,21 @@
+package gormix
+
+import "gorm.io/gorm"
+
+const (
+	RoleRead  = "read"
+	RoleWrite = "write"
+	// RoleSetting is a statement setting that overrides the role of the pool a
+	// statement runs on, e.g. for reads that fall back to the primary.
+	RoleSetting = "spreaddb:role"
+)
+
+// RoleOf returns the role set on db's statement with RoleSetting, or fallback.
+func RoleOf(db *gorm.DB, fallback string) string {
+	if role, ok := db.Get(RoleSetting); ok {
+		if role, ok := role.(string); ok {
+			return role
+		}
+	}
+	return fallback
+}
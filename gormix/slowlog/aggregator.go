@@ -0,0 +1,85 @@
+package slowlog
+
+import (
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+type Stats struct {
+	Fingerprint string
+	Role        string
+	Count       int64
+	Total       time.Duration
+	Max         time.Duration
+	Rows        int64
+	// Caller of the slowest occurrence.
+	Caller string
+}
+
+type statsKey struct {
+	role        string
+	fingerprint string
+}
+
+// Aggregator groups slow statements by role and fingerprint. Use its Record
+// method as Config.Handler.
+type Aggregator struct {
+	mu    sync.Mutex
+	stats map[statsKey]*Stats
+}
+
+func NewAggregator() *Aggregator {
+	return &Aggregator{stats: make(map[statsKey]*Stats)}
+}
+
+func (a *Aggregator) Record(entry Entry) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	key := statsKey{role: entry.Role, fingerprint: entry.Fingerprint}
+	stats, ok := a.stats[key]
+	if !ok {
+		stats = &Stats{Fingerprint: entry.Fingerprint, Role: entry.Role}
+		a.stats[key] = stats
+	}
+	stats.Count++
+	stats.Total += entry.Duration
+	stats.Rows += entry.Rows
+	if entry.Duration >= stats.Max {
+		stats.Max = entry.Duration
+		stats.Caller = entry.Caller
+	}
+}
+
+// Top returns the n fingerprints with the highest total time, limited to the
+// given roles if any.
+func (a *Aggregator) Top(n int, roles ...string) []Stats {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	top := make([]Stats, 0, len(a.stats))
+	for _, stats := range a.stats {
+		if len(roles) > 0 && !slices.Contains(roles, stats.Role) {
+			continue
+		}
+		top = append(top, *stats)
+	}
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Total != top[j].Total {
+			return top[i].Total > top[j].Total
+		}
+		return top[i].Fingerprint < top[j].Fingerprint
+	})
+	if n >= 0 && len(top) > n {
+		top = top[:n]
+	}
+	return top
+}
+
+func (a *Aggregator) Reset() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.stats = make(map[statsKey]*Stats)
+}
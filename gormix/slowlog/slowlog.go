@@ -0,0 +1,120 @@
+package slowlog
+
+import (
+	"github.com/XuanHieuHo/spread-db/gormix"
+	"github.com/XuanHieuHo/spread-db/gormix/sqlnorm"
+	"gorm.io/gorm"
+	"log"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	PluginName = "spreaddb:slow_query_log"
+	startKey   = "spreaddb:slow_query_start"
+)
+
+type Entry struct {
+	Fingerprint string
+	// Statement is the executed SQL with its literals replaced by ?.
+	Statement string
+	Role      string
+	Replica   string
+	Duration  time.Duration
+	Rows      int64
+	Error     error
+	// Caller is the file:line of the first frame outside gorm and this module.
+	Caller    string
+	StartedAt time.Time
+}
+
+type Config struct {
+	// Threshold is the duration from which a statement is slow. Defaults to 200ms.
+	Threshold time.Duration
+	// Handler receives every slow statement, e.g. Aggregator.Record. Defaults to
+	// a line on the standard logger.
+	Handler func(entry Entry)
+}
+
+func (c Config) withDefaults() Config {
+	if c.Threshold <= 0 {
+		c.Threshold = 200 * time.Millisecond
+	}
+	if c.Handler == nil {
+		c.Handler = func(entry Entry) {
+			log.Printf("slow query %s role=%s replica=%s rows=%d at %s: %s",
+				entry.Duration, entry.Role, entry.Replica, entry.Rows, entry.Caller, entry.Statement)
+		}
+	}
+	return c
+}
+
+// Plugin is a gorm plugin that hands statements slower than the threshold to the handler.
+type Plugin struct {
+	cfg     Config
+	role    string
+	replica string
+}
+
+func NewPlugin(cfg Config, role string, replica string) *Plugin {
+	return &Plugin{cfg: cfg.withDefaults(), role: role, replica: replica}
+}
+
+func (p *Plugin) Name() string {
+	return PluginName
+}
+
+func (p *Plugin) Initialize(db *gorm.DB) error {
+	return gormix.RegisterAround(db, "spreaddb:slow_query", p.start, p.end)
+}
+
+func (p *Plugin) start(_ string, db *gorm.DB) {
+	db.InstanceSet(startKey, time.Now())
+}
+
+func (p *Plugin) end(_ string, db *gorm.DB) {
+	value, ok := db.InstanceGet(startKey)
+	if !ok || db.DryRun {
+		return
+	}
+	startedAt := value.(time.Time)
+	duration := time.Since(startedAt)
+	if duration < p.cfg.Threshold {
+		return
+	}
+
+	query := db.Statement.SQL.String()
+	p.cfg.Handler(Entry{
+		Fingerprint: sqlnorm.Fingerprint(query, db.Dialector.Name()),
+		Statement:   sqlnorm.Sanitize(query, db.Dialector.Name()),
+		Role:        gormix.RoleOf(db, p.role),
+		Replica:     p.replica,
+		Duration:    duration,
+		Rows:        db.RowsAffected,
+		Error:       db.Error,
+		Caller:      caller(),
+		StartedAt:   startedAt,
+	})
+}
+
+const modulePrefix = "github.com/XuanHieuHo/spread-db/"
+
+// caller returns the file:line of the code that issued the statement, skipping
+// gorm and this module, except for its tests.
+func caller() string {
+	pcs := make([]uintptr, 32)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(3, pcs)])
+	for {
+		frame, more := frames.Next()
+		internal := strings.HasPrefix(frame.Function, "gorm.io/") ||
+			(strings.HasPrefix(frame.Function, modulePrefix) && !strings.HasSuffix(frame.File, "_test.go"))
+		if !internal && frame.File != "" {
+			return frame.File + ":" + strconv.Itoa(frame.Line)
+		}
+		if !more {
+			return ""
+		}
+	}
+}
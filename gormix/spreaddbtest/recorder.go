@@ -0,0 +1,33 @@
+package spreaddbtest
+
+import (
+	"github.com/XuanHieuHo/spread-db/gormix"
+	"gorm.io/gorm"
+)
+
+// recorder is a gorm plugin that records every statement run on a pool into db.
+type recorder struct {
+	db   *DB
+	role string
+}
+
+func (r *recorder) Name() string {
+	return pluginName
+}
+
+func (r *recorder) Initialize(db *gorm.DB) error {
+	return gormix.RegisterAround(db, pluginName, nil, r.record)
+}
+
+func (r *recorder) record(operation string, db *gorm.DB) {
+	if db.DryRun {
+		return
+	}
+	r.db.record(Query{
+		Role:      gormix.RoleOf(db, r.role),
+		Operation: operation,
+		SQL:       db.Statement.SQL.String(),
+		Vars:      append([]interface{}(nil), db.Statement.Vars...),
+		Error:     db.Error,
+	})
+}
@@ -0,0 +1,144 @@
+// Package spreaddbtest provides a DBProvider backed by an in-memory SQLite
+// database for unit tests, with assertions on the statements each role ran.
+// The SQLite driver is pure Go, so tests run with CGO_ENABLED=0.
+package spreaddbtest
+
+import (
+	"fmt"
+	"github.com/XuanHieuHo/spread-db/gormix"
+	"github.com/XuanHieuHo/spread-db/gormix/balancer"
+	"github.com/XuanHieuHo/spread-db/gormix/provider"
+	"github.com/XuanHieuHo/spread-db/gormix/readonly"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+const pluginName = "spreaddbtest:recorder"
+
+var databaseSeq atomic.Uint64
+
+type Query struct {
+	Role      string
+	Operation string
+	SQL       string
+	Vars      []interface{}
+	Error     error
+}
+
+// Write reports whether the query creates, changes or deletes data.
+func (q Query) Write() bool {
+	switch q.Operation {
+	case "create", "update", "delete":
+		return true
+	}
+	return q.SQL != "" && readonly.IsWriteStatement(q.SQL, "sqlite")
+}
+
+// DB is a DBProvider whose read and write pools share one in-memory database,
+// so reads see writes immediately. The read pool is guarded like a replica.
+type DB struct {
+	*provider.DBProvider
+
+	t       testing.TB
+	mu      sync.Mutex
+	queries []Query
+}
+
+// New returns a DB with the given models migrated. It is closed when the test ends.
+func New(t testing.TB, models ...interface{}) *DB {
+	t.Helper()
+
+	db := &DB{t: t}
+	dsn := fmt.Sprintf("file:spreaddbtest%d?mode=memory&cache=shared", databaseSeq.Add(1))
+	writeDB := db.open(dsn, gormix.RoleWrite)
+	readDB := db.open(dsn, gormix.RoleRead)
+	if err := writeDB.AutoMigrate(models...); err != nil {
+		t.Fatalf("spreaddbtest: migrate models: %v", err)
+	}
+
+	p, err := provider.NewDBProviderWithReplicas(writeDB, []*balancer.Replica{
+		balancer.NewReplica("memory", readDB, 1),
+	}, provider.WithReadOnlyGuard())
+	if err != nil {
+		t.Fatalf("spreaddbtest: create provider: %v", err)
+	}
+	t.Cleanup(p.Close)
+	db.DBProvider = p
+
+	db.Reset()
+	return db
+}
+
+func (db *DB) open(dsn string, role string) *gorm.DB {
+	db.t.Helper()
+
+	gormDB, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		db.t.Fatalf("spreaddbtest: open %s pool: %v", role, err)
+	}
+	sqlDB, err := gormDB.DB()
+	if err != nil {
+		db.t.Fatalf("spreaddbtest: open %s pool: %v", role, err)
+	}
+	// a single connection per pool keeps the shared-cache database alive and avoids table locks
+	sqlDB.SetMaxOpenConns(1)
+	db.t.Cleanup(func() {
+		sqlDB.Close()
+	})
+	if err := gormDB.Use(&recorder{db: db, role: role}); err != nil {
+		db.t.Fatalf("spreaddbtest: open %s pool: %v", role, err)
+	}
+	return gormDB
+}
+
+func (db *DB) record(query Query) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.queries = append(db.queries, query)
+}
+
+// Queries returns the statements run since New or the last Reset, in order,
+// limited to the given roles if any.
+func (db *DB) Queries(roles ...string) []Query {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	queries := make([]Query, 0, len(db.queries))
+	for _, query := range db.queries {
+		if len(roles) == 0 || slices.Contains(roles, query.Role) {
+			queries = append(queries, query)
+		}
+	}
+	return queries
+}
+
+// Reset forgets the statements recorded so far, e.g. after seeding data.
+func (db *DB) Reset() {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.queries = nil
+}
+
+// AssertNoWritesOnRead fails the test if a write was attempted through the
+// read role, even if it was rejected and the error ignored.
+func (db *DB) AssertNoWritesOnRead() {
+	db.t.Helper()
+	for _, query := range db.Queries(gormix.RoleRead) {
+		if query.Write() {
+			db.t.Errorf("spreaddbtest: write on read role: %s %s", query.Operation, query.SQL)
+		}
+	}
+}
+
+// AssertQueryCount fails the test unless exactly want statements ran with role.
+func (db *DB) AssertQueryCount(role string, want int) {
+	db.t.Helper()
+	if got := len(db.Queries(role)); got != want {
+		db.t.Errorf("spreaddbtest: %d %s queries, want %d", got, role, want)
+	}
+}
@@ -0,0 +1,55 @@
+package sqlnorm
+
+import (
+	"github.com/XuanHieuHo/spread-db/gormix/internal/sqllex"
+	"regexp"
+	"strings"
+)
+
+// Sanitize replaces string and numeric literals in query with ? and drops
+// comments, so that the result can be logged or traced without leaking values.
+// Identifiers, keywords and bind placeholders are kept as they are. dialect is
+// the gorm dialector name; it decides whether backslashes escape quotes and
+// whether double quotes delimit identifiers or strings.
+func Sanitize(query string, dialect string) string {
+	var b strings.Builder
+	b.Grow(len(query))
+	for _, token := range sqllex.Tokenize(query, dialect) {
+		switch token.Kind {
+		case sqllex.String, sqllex.Number:
+			b.WriteByte('?')
+		case sqllex.Comment:
+			// dropped, comments can carry values too
+		default:
+			b.WriteString(token.Text)
+		}
+	}
+	return b.String()
+}
+
+// Operation returns the upper-cased first keyword of query, e.g. SELECT or INSERT.
+func Operation(query string, dialect string) string {
+	for _, field := range strings.Fields(Sanitize(query, dialect)) {
+		field = strings.TrimLeft(field, "(")
+		if field != "" {
+			return strings.ToUpper(field)
+		}
+	}
+	return ""
+}
+
+var (
+	placeholderPattern = regexp.MustCompile(`\$\d+`)
+	inListPattern      = regexp.MustCompile(`(?i)\bIN\s*\(\s*\?(?:\s*,\s*\?)*\s*\)`)
+	spacePattern       = regexp.MustCompile(`\s+`)
+)
+
+// Fingerprint normalizes query so that statements differing only in their
+// values share the same text: literals and placeholders become ?, IN lists
+// collapse to IN (...) and whitespace is squeezed.
+func Fingerprint(query string, dialect string) string {
+	fingerprint := placeholderPattern.ReplaceAllString(Sanitize(query, dialect), "?")
+	fingerprint = spacePattern.ReplaceAllString(fingerprint, " ")
+	fingerprint = inListPattern.ReplaceAllString(fingerprint, "IN (...)")
+	return strings.TrimSpace(fingerprint)
+}
@@ -0,0 +1,53 @@
+package gormix
+
+import (
+	"context"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// StatementView is a read-only copy of the statement behind a ReadOnlyDB chain.
+// Unlike *gorm.Statement it gives no way back to a writable connection.
+type StatementView struct {
+	Context  context.Context
+	Table    string
+	Schema   *schema.Schema
+	SQL      string
+	Vars     []interface{}
+	Selects  []string
+	Omits    []string
+	Distinct bool
+	Unscoped bool
+	Clauses  map[string]clause.Clause
+	Joins    []Join
+	Preloads map[string][]interface{}
+}
+
+type Join struct {
+	Name     string
+	Conds    []interface{}
+	JoinType clause.JoinType
+}
+
+// Dialect is the part of gorm.Dialector that is safe to hand out on the read side.
+type Dialect interface {
+	Name() string
+	Explain(sql string, vars ...interface{}) string
+}
+
+// SnapshotOptions configures ReadOnlyDB.ReadSnapshot.
+type SnapshotOptions struct {
+	// Deferrable upgrades the snapshot to SERIALIZABLE READ ONLY DEFERRABLE, which
+	// waits for a snapshot that cannot observe a serialization anomaly. Postgres only.
+	Deferrable bool
+}
+
+// LockOptions configures WriteOnlyDB.LockForUpdate and LockForShare.
+type LockOptions struct {
+	// Of limits the lock to the rows of this table when the query joins several.
+	Of string
+	// SkipLocked leaves out rows locked by another transaction instead of waiting.
+	SkipLocked bool
+	// NoWait fails right away instead of waiting for rows locked by another transaction.
+	NoWait bool
+}
@@ -0,0 +1,63 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"github.com/XuanHieuHo/spread-db/gormix"
+	"iter"
+)
+
+var errStopped = errors.New("stream: stopped by consumer")
+
+// Rows yields the rows selected by db one at a time, scanned into T, holding a
+// single open cursor. Breaking out of the loop closes the cursor. A query,
+// scan or context error is yielded once, as the last element.
+func Rows[T any](ctx context.Context, db gormix.ReadOnlyDB) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var zero T
+		q := db.WithContext(ctx).Model(new(T))
+		rows, err := q.Rows()
+		if err != nil {
+			yield(zero, err)
+			return
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			if err := ctx.Err(); err != nil {
+				yield(zero, err)
+				return
+			}
+			var item T
+			if err := q.ScanRows(rows, &item); err != nil {
+				yield(zero, err)
+				return
+			}
+			if !yield(item, nil) {
+				return
+			}
+		}
+		if err := rows.Err(); err != nil {
+			yield(zero, err)
+		}
+	}
+}
+
+// Batches yields the rows selected by db in slices of at most batchSize, fetched
+// with FindInBatches, so at most one batch is held in memory. A query or
+// context error is yielded once, as the last element.
+func Batches[T any](ctx context.Context, db gormix.ReadOnlyDB, batchSize int) iter.Seq2[[]T, error] {
+	return func(yield func([]T, error) bool) {
+		var batch []T
+		err := db.WithContext(ctx).Model(new(T)).FindInBatches(&batch, batchSize, func(_ gormix.ReadOnlyDB, _ int) error {
+			// gorm reuses batch's backing array for the next query
+			if !yield(append([]T(nil), batch...), nil) {
+				return errStopped
+			}
+			return ctx.Err()
+		}).Error()
+		if err != nil && !errors.Is(err, errStopped) {
+			yield(nil, err)
+		}
+	}
+}
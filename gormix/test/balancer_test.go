@@ -0,0 +1,138 @@
+package test
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/XuanHieuHo/spread-db/gormix/balancer"
+	"github.com/stretchr/testify/require"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestBalancer_RoundRobin(t *testing.T) {
+	replicas, _ := setupTestReplicas(t, 3)
+	b := balancer.NewRoundRobin()
+
+	var picked []string
+	for i := 0; i < 6; i++ {
+		picked = append(picked, b.Pick(context.Background(), replicas).Name)
+	}
+
+	require.Equal(t, []string{"a", "b", "c", "a", "b", "c"}, picked)
+}
+
+func TestBalancer_Random(t *testing.T) {
+	replicas, _ := setupTestReplicas(t, 3)
+	b := balancer.NewRandom()
+
+	seen := map[string]int{}
+	for i := 0; i < 300; i++ {
+		seen[b.Pick(context.Background(), replicas).Name]++
+	}
+
+	require.Len(t, seen, 3)
+}
+
+func TestBalancer_Weighted(t *testing.T) {
+	tests := map[string]struct {
+		weights []int
+	}{
+		"heavier replica gets more traffic": {
+			weights: []int{8, 1, 1},
+		},
+		"non-positive weight counts as one": {
+			weights: []int{0, -5, 1},
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			replicas, _ := setupTestReplicas(t, len(tc.weights))
+			total := 0
+			for i, weight := range tc.weights {
+				replicas[i].Weight = weight
+				if weight > 0 {
+					total += weight
+				} else {
+					total++
+				}
+			}
+			b := balancer.NewWeighted()
+
+			const picks = 20000
+			seen := map[string]int{}
+			for i := 0; i < picks; i++ {
+				seen[b.Pick(context.Background(), replicas).Name]++
+			}
+
+			for i, replica := range replicas {
+				weight := tc.weights[i]
+				if weight <= 0 {
+					weight = 1
+				}
+				want := float64(picks) * float64(weight) / float64(total)
+				require.InDelta(t, want, float64(seen[replica.Name]), want*0.15)
+			}
+		})
+	}
+}
+
+func TestBalancer_LeastInFlight(t *testing.T) {
+	replicas, mocks := setupTestReplicas(t, 2)
+	for _, replica := range replicas {
+		require.NoError(t, replica.Track())
+	}
+	b := balancer.NewLeastInFlight()
+
+	mocks[0].ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "user_dummies"`)).
+		WillDelayFor(200 * time.Millisecond).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	done := make(chan error)
+	go func() {
+		var users []UserDummy
+		done <- replicas[0].DB.Find(&users).Error
+	}()
+	require.Eventually(t, func() bool {
+		return replicas[0].InFlight() == 1
+	}, time.Second, time.Millisecond)
+
+	for i := 0; i < 4; i++ {
+		require.Equal(t, "b", b.Pick(context.Background(), replicas).Name)
+	}
+
+	require.NoError(t, <-done)
+	require.Equal(t, int64(0), replicas[0].InFlight())
+	require.NoError(t, mocks[0].ExpectationsWereMet())
+}
+
+func TestReplica_TrackSharedPool(t *testing.T) {
+	db, mock := setupMockGormDB(t)
+	// the same pool registered as two replicas
+	replicas := []*balancer.Replica{balancer.NewReplica("a", db, 1), balancer.NewReplica("b", db, 1)}
+	for _, replica := range replicas {
+		require.NoError(t, replica.Track())
+		require.NoError(t, replica.Track())
+	}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "user_dummies"`)).
+		WillDelayFor(200 * time.Millisecond).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	done := make(chan error)
+	go func() {
+		var users []UserDummy
+		done <- db.Find(&users).Error
+	}()
+	require.Eventually(t, func() bool {
+		return replicas[0].InFlight() == 1
+	}, time.Second, time.Millisecond)
+	require.Equal(t, int64(1), replicas[1].InFlight())
+
+	require.NoError(t, <-done)
+	require.Equal(t, int64(0), replicas[0].InFlight())
+	require.Equal(t, int64(0), replicas[1].InFlight())
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,159 @@
+package test
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/XuanHieuHo/spread-db/gormix"
+	"github.com/XuanHieuHo/spread-db/gormix/consistency"
+	"github.com/XuanHieuHo/spread-db/gormix/provider"
+	"github.com/stretchr/testify/require"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func expectUserInsert(mock sqlmock.Sqlmock) {
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "user_dummies" ("name","email") VALUES ($1,$2) RETURNING "id"`)).
+		WithArgs("User 1", "Email1@example.com").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
+}
+
+func expectUserSelect(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "user_dummies"`)).
+		WillReturnRows(createDummyUsers(1))
+}
+
+func TestDBProvider_ReadYourWritesRoutesToPrimary(t *testing.T) {
+	tests := map[string]struct {
+		withSession bool
+		sleep       time.Duration
+		wantPrimary bool
+	}{
+		"read right after write goes to primary": {
+			withSession: true,
+			wantPrimary: true,
+		},
+		"read after window goes to replica": {
+			withSession: true,
+			sleep:       60 * time.Millisecond,
+			wantPrimary: false,
+		},
+		"context without session goes to replica": {
+			withSession: false,
+			wantPrimary: false,
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			writeDB, writeMock := setupMockGormDB(t)
+			replicas, mocks := setupTestReplicas(t, 1)
+			dbProvider, err := provider.NewDBProviderWithReplicas(writeDB, replicas,
+				provider.WithReadYourWrites(consistency.Config{Window: 50 * time.Millisecond}))
+			require.NoError(t, err)
+			ctx := context.Background()
+			if tc.withSession {
+				ctx = consistency.NewContext(ctx)
+			}
+
+			expectUserInsert(writeMock)
+			if tc.wantPrimary {
+				expectUserSelect(writeMock)
+			} else {
+				expectUserSelect(mocks[0])
+			}
+
+			user := UserDummy{Name: "User 1", Email: "Email1@example.com"}
+			require.NoError(t, dbProvider.Write.WithContext(ctx).Create(&user).Error())
+			time.Sleep(tc.sleep)
+			var users []UserDummy
+			require.NoError(t, dbProvider.Read.WithContext(ctx).Find(&users).Error())
+
+			require.NoError(t, writeMock.ExpectationsWereMet())
+			require.NoError(t, mocks[0].ExpectationsWereMet())
+		})
+	}
+}
+
+func TestDBProvider_ReadYourWritesWaitsForReplica(t *testing.T) {
+	tests := map[string]struct {
+		setupReplayMock func(mock sqlmock.Sqlmock)
+		wantPrimary     bool
+	}{
+		"replica catches up": {
+			setupReplayMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(`SELECT pg_last_wal_replay_lsn() >= $1::pg_lsn`)).
+					WithArgs("0/3000060").
+					WillReturnRows(sqlmock.NewRows([]string{"replayed"}).AddRow(false))
+				mock.ExpectQuery(regexp.QuoteMeta(`SELECT pg_last_wal_replay_lsn() >= $1::pg_lsn`)).
+					WithArgs("0/3000060").
+					WillReturnRows(sqlmock.NewRows([]string{"replayed"}).AddRow(true))
+			},
+			wantPrimary: false,
+		},
+		"replica too slow": {
+			setupReplayMock: func(mock sqlmock.Sqlmock) {
+				mock.MatchExpectationsInOrder(false)
+				for i := 0; i < 100; i++ {
+					mock.ExpectQuery(regexp.QuoteMeta(`SELECT pg_last_wal_replay_lsn() >= $1::pg_lsn`)).
+						WithArgs("0/3000060").
+						WillReturnRows(sqlmock.NewRows([]string{"replayed"}).AddRow(false))
+				}
+			},
+			wantPrimary: true,
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			writeDB, writeMock := setupMockGormDB(t)
+			replicas, mocks := setupTestReplicas(t, 1)
+			dbProvider, err := provider.NewDBProviderWithReplicas(writeDB, replicas,
+				provider.WithReadYourWrites(consistency.Config{
+					WaitForReplica: true,
+					WaitTimeout:    50 * time.Millisecond,
+					PollInterval:   time.Millisecond,
+				}))
+			require.NoError(t, err)
+			ctx := consistency.NewContext(context.Background())
+
+			writeMock.ExpectBegin()
+			writeMock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "cities" ("name") VALUES ($1)`)).
+				WithArgs("Hanoi").
+				WillReturnResult(sqlmock.NewResult(1, 1))
+			writeMock.ExpectCommit()
+			writeMock.ExpectQuery(regexp.QuoteMeta(`SELECT pg_current_wal_lsn()::text`)).
+				WillReturnRows(sqlmock.NewRows([]string{"lsn"}).AddRow("0/3000060"))
+			tc.setupReplayMock(mocks[0])
+
+			err = dbProvider.Write.WithContext(ctx).Transaction(func(tx gormix.WriteOnlyDB) error {
+				err := tx.Exec(`INSERT INTO "cities" ("name") VALUES (?)`, "Hanoi").Error()
+				session, _ := consistency.FromContext(ctx)
+				_, lsn := session.LastWrite()
+				require.Empty(t, lsn)
+				return err
+			})
+			require.NoError(t, err)
+			session, _ := consistency.FromContext(ctx)
+			_, lsn := session.LastWrite()
+			require.Equal(t, "0/3000060", lsn)
+
+			if tc.wantPrimary {
+				expectUserSelect(writeMock)
+			} else {
+				expectUserSelect(mocks[0])
+			}
+			var users []UserDummy
+			require.NoError(t, dbProvider.Read.WithContext(ctx).Find(&users).Error())
+
+			require.NoError(t, writeMock.ExpectationsWereMet())
+			if !tc.wantPrimary {
+				require.NoError(t, mocks[0].ExpectationsWereMet())
+			}
+		})
+	}
+}
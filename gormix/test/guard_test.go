@@ -0,0 +1,230 @@
+package test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/XuanHieuHo/spread-db/constant"
+	"github.com/XuanHieuHo/spread-db/gormix/balancer"
+	"github.com/XuanHieuHo/spread-db/gormix/provider"
+	"github.com/XuanHieuHo/spread-db/gormix/readonly"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"regexp"
+	"testing"
+)
+
+type dsnConnector struct {
+	dsn string
+	drv driver.Driver
+}
+
+func (c dsnConnector) Connect(context.Context) (driver.Conn, error) {
+	return c.drv.Open(c.dsn)
+}
+
+func (c dsnConnector) Driver() driver.Driver {
+	return c.drv
+}
+
+func TestGuardConnector(t *testing.T) {
+	tests := map[string]struct {
+		setupMock func(mock sqlmock.Sqlmock)
+		wantErr   error
+	}{
+		"success: session set read-only before first query": {
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(regexp.QuoteMeta(`SET default_transaction_read_only = on`)).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "user_dummies"`)).
+					WillReturnRows(createDummyUsers(1))
+			},
+		},
+		"failure: session cannot be set": {
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(regexp.QuoteMeta(`SET default_transaction_read_only = on`)).
+					WillReturnError(assert.AnError)
+			},
+			wantErr: assert.AnError,
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			mockDB, mock, err := sqlmock.NewWithDSN("guard_" + t.Name())
+			require.NoError(t, err)
+			defer mockDB.Close()
+			tc.setupMock(mock)
+
+			connector, err := readonly.GuardConnector(dsnConnector{dsn: "guard_" + t.Name(), drv: mockDB.Driver()}, "postgres")
+			require.NoError(t, err)
+			sqlDB := sql.OpenDB(connector)
+			defer sqlDB.Close()
+			db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{DisableAutomaticPing: true})
+			require.NoError(t, err)
+
+			var users []UserDummy
+			err = readonly.New(db).Find(&users).Error()
+
+			require.Equal(t, tc.wantErr, err)
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestGuardConnector_UnsupportedDialect(t *testing.T) {
+	connector, err := readonly.GuardConnector(dsnConnector{}, "oracle")
+
+	require.Nil(t, connector)
+	require.Equal(t, constant.ErrUnsupportedDialect, err)
+}
+
+func TestGuard(t *testing.T) {
+	tests := map[string]struct {
+		run     func(db *gorm.DB) error
+		wantErr error
+	}{
+		"create": {
+			run: func(db *gorm.DB) error {
+				return db.Create(&UserDummy{Name: "User 1"}).Error
+			},
+			wantErr: constant.ErrWriteOperationOnReadDB,
+		},
+		"save": {
+			run: func(db *gorm.DB) error {
+				return db.Save(&UserDummy{ID: 1, Name: "User 1"}).Error
+			},
+			wantErr: constant.ErrWriteOperationOnReadDB,
+		},
+		"update": {
+			run: func(db *gorm.DB) error {
+				return db.Model(&UserDummy{ID: 1}).Update("name", "User 2").Error
+			},
+			wantErr: constant.ErrWriteOperationOnReadDB,
+		},
+		"delete": {
+			run: func(db *gorm.DB) error {
+				return db.Delete(&UserDummy{ID: 1}).Error
+			},
+			wantErr: constant.ErrWriteOperationOnReadDB,
+		},
+		"exec write": {
+			run: func(db *gorm.DB) error {
+				return db.Exec("DELETE FROM user_dummies").Error
+			},
+			wantErr: constant.ErrWriteOperationOnReadDB,
+		},
+		"raw write scanned": {
+			run: func(db *gorm.DB) error {
+				return db.Raw("UPDATE user_dummies SET name = 'x' RETURNING *").Scan(&[]UserDummy{}).Error
+			},
+			wantErr: constant.ErrWriteOperationOnReadDB,
+		},
+		"raw write rows": {
+			run: func(db *gorm.DB) error {
+				_, err := db.Raw("INSERT INTO user_dummies (name) VALUES ('x') RETURNING id").Rows()
+				return err
+			},
+			wantErr: constant.ErrWriteOperationOnReadDB,
+		},
+		"earlier error kept": {
+			run: func(db *gorm.DB) error {
+				tx := db.Session(&gorm.Session{})
+				tx.AddError(assert.AnError)
+				return tx.Exec("DELETE FROM user_dummies").Error
+			},
+			wantErr: assert.AnError,
+		},
+		"locking query": {
+			run: func(db *gorm.DB) error {
+				return db.Clauses(clause.Locking{Strength: clause.LockingStrengthUpdate}).Find(&[]UserDummy{}).Error
+			},
+			wantErr: constant.ErrLockOnReadDB,
+		},
+		"raw locking query": {
+			run: func(db *gorm.DB) error {
+				return db.Raw("SELECT * FROM user_dummies FOR UPDATE SKIP LOCKED").Scan(&[]UserDummy{}).Error
+			},
+			wantErr: constant.ErrLockOnReadDB,
+		},
+		"query": {
+			run: func(db *gorm.DB) error {
+				return db.Where("id = ?", 1).Find(&[]UserDummy{}).Error
+			},
+		},
+		"raw read": {
+			run: func(db *gorm.DB) error {
+				return db.Raw("SELECT * FROM user_dummies").Scan(&[]UserDummy{}).Error
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			db, mock := setupMockGormDB(t)
+			require.NoError(t, readonly.Guard(db))
+			if tc.wantErr == nil {
+				mock.ExpectQuery(`SELECT`).WillReturnRows(createDummyUsers(1))
+			}
+
+			err := tc.run(db)
+
+			require.Equal(t, tc.wantErr, err)
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestDBProvider_WithReadOnlyGuard(t *testing.T) {
+	writeDB, writeMock := setupMockGormDB(t)
+	replicas, mocks := setupTestReplicas(t, 2)
+	_, err := provider.NewDBProviderWithReplicas(writeDB, replicas, provider.WithReadOnlyGuard())
+	require.NoError(t, err)
+
+	for _, replica := range replicas {
+		err := replica.DB.Create(&UserDummy{Name: "User 1"}).Error
+		require.Equal(t, constant.ErrWriteOperationOnReadDB, err)
+	}
+
+	writeMock.ExpectBegin()
+	writeMock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "user_dummies"`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	writeMock.ExpectCommit()
+	require.NoError(t, writeDB.Create(&UserDummy{Name: "User 1"}).Error)
+
+	for _, mock := range append(mocks, writeMock) {
+		require.NoError(t, mock.ExpectationsWereMet())
+	}
+}
+
+func TestDBProvider_WithReadOnlyGuard_GuardedConnections(t *testing.T) {
+	writeDB, _ := setupMockGormDB(t)
+	mockDB, mock, err := sqlmock.NewWithDSN("guard_" + t.Name())
+	require.NoError(t, err)
+	defer mockDB.Close()
+	connector, err := readonly.GuardConnector(dsnConnector{dsn: "guard_" + t.Name(), drv: mockDB.Driver()}, "postgres")
+	require.NoError(t, err)
+	sqlDB := sql.OpenDB(connector)
+	defer sqlDB.Close()
+	replicaDB, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{DisableAutomaticPing: true})
+	require.NoError(t, err)
+	dbProvider, err := provider.NewDBProviderWithReplicas(writeDB, []*balancer.Replica{balancer.NewReplica("a", replicaDB, 1)}, provider.WithReadOnlyGuard())
+	require.NoError(t, err)
+
+	mock.ExpectExec(regexp.QuoteMeta(`SET default_transaction_read_only = on`)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT name FROM user_dummies`)).
+		WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("User 1"))
+
+	rows, err := dbProvider.Read.Raw("SELECT name FROM user_dummies").Rows()
+	require.NoError(t, err)
+	require.NoError(t, rows.Close())
+	require.Equal(t, constant.ErrWriteOperationOnReadDB, replicaDB.Create(&UserDummy{Name: "User 1"}).Error)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
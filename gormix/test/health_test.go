@@ -0,0 +1,123 @@
+package test
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/XuanHieuHo/spread-db/gormix/balancer"
+	"github.com/XuanHieuHo/spread-db/gormix/health"
+	"github.com/XuanHieuHo/spread-db/gormix/provider"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func setupPingMockReplica(t *testing.T, name string) (*balancer.Replica, sqlmock.Sqlmock) {
+	sqlDB, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		sqlDB.Close()
+	})
+
+	db, err := gorm.Open(postgres.New(postgres.Config{
+		Conn:       sqlDB,
+		DriverName: "postgres",
+	}), &gorm.Config{DisableAutomaticPing: true})
+	require.NoError(t, err)
+
+	return balancer.NewReplica(name, db, 1), mock
+}
+
+func TestHealthChecker_EjectAndReadmit(t *testing.T) {
+	replica, mock := setupPingMockReplica(t, "a")
+	checker := health.NewChecker([]*balancer.Replica{replica}, health.Config{
+		FailureThreshold: 2,
+		SuccessThreshold: 2,
+	})
+
+	steps := []struct {
+		pingErr     error
+		wantHealthy bool
+	}{
+		{pingErr: assert.AnError, wantHealthy: true},
+		{pingErr: assert.AnError, wantHealthy: false},
+		{pingErr: nil, wantHealthy: false},
+		{pingErr: assert.AnError, wantHealthy: false},
+		{pingErr: nil, wantHealthy: false},
+		{pingErr: nil, wantHealthy: true},
+	}
+
+	require.True(t, checker.Healthy(replica))
+	for i, step := range steps {
+		mock.ExpectPing().WillReturnError(step.pingErr)
+
+		checker.CheckNow(context.Background())
+
+		require.Equal(t, step.wantHealthy, checker.Healthy(replica), "step %d", i)
+		status := checker.Status()[0]
+		require.Equal(t, "a", status.Replica)
+		require.Equal(t, step.wantHealthy, status.Healthy)
+		require.Equal(t, step.pingErr, status.LastError)
+	}
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestHealthChecker_UnknownReplica(t *testing.T) {
+	replicas, _ := setupTestReplicas(t, 2)
+	checker := health.NewChecker(replicas[:1], health.Config{})
+
+	require.True(t, checker.Healthy(replicas[0]))
+	require.False(t, checker.Healthy(replicas[1]))
+}
+
+func TestDBProvider_HealthCheckEjectsReplica(t *testing.T) {
+	writeDB, _ := setupMockGormDB(t)
+	healthy, healthyMock := setupTestReplicas(t, 1)
+	down, downMock := setupPingMockReplica(t, "down")
+	dbProvider, err := provider.NewDBProviderWithReplicas(writeDB, []*balancer.Replica{healthy[0], down},
+		provider.WithHealthCheck(health.Config{Interval: 5 * time.Millisecond, FailureThreshold: 1}))
+	require.NoError(t, err)
+	defer dbProvider.Close()
+
+	require.Eventually(t, func() bool {
+		return !dbProvider.Health()[1].Healthy
+	}, time.Second, 5*time.Millisecond)
+
+	for i := 0; i < 3; i++ {
+		healthyMock[0].ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "user_dummies"`)).
+			WillReturnRows(createDummyUsers(1))
+	}
+	for i := 0; i < 3; i++ {
+		var users []UserDummy
+		require.NoError(t, dbProvider.Read.Find(&users).Error())
+	}
+
+	require.True(t, dbProvider.Health()[0].Healthy)
+	require.NoError(t, healthyMock[0].ExpectationsWereMet())
+	require.NoError(t, downMock.ExpectationsWereMet())
+}
+
+func TestDBProvider_HealthCheckFallsBackToPrimary(t *testing.T) {
+	writeDB, writeMock := setupMockGormDB(t)
+	first, _ := setupPingMockReplica(t, "a")
+	second, _ := setupPingMockReplica(t, "b")
+	dbProvider, err := provider.NewDBProviderWithReplicas(writeDB, []*balancer.Replica{first, second},
+		provider.WithHealthCheck(health.Config{Interval: 5 * time.Millisecond, FailureThreshold: 1}))
+	require.NoError(t, err)
+	defer dbProvider.Close()
+
+	require.Eventually(t, func() bool {
+		statuses := dbProvider.Health()
+		return !statuses[0].Healthy && !statuses[1].Healthy
+	}, time.Second, 5*time.Millisecond)
+
+	writeMock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "user_dummies"`)).
+		WillReturnRows(createDummyUsers(1))
+	var users []UserDummy
+	require.NoError(t, dbProvider.Read.Find(&users).Error())
+	require.Len(t, users, 1)
+	require.NoError(t, writeMock.ExpectationsWereMet())
+}
@@ -0,0 +1,208 @@
+package test
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/XuanHieuHo/spread-db/constant"
+	"github.com/XuanHieuHo/spread-db/gormix/balancer"
+	"github.com/XuanHieuHo/spread-db/gormix/lag"
+	"github.com/XuanHieuHo/spread-db/gormix/provider"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeLagProbe struct {
+	mu   sync.Mutex
+	lags map[*gorm.DB]time.Duration
+	errs map[*gorm.DB]error
+}
+
+func (f *fakeLagProbe) set(db *gorm.DB, lag time.Duration, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.lags[db] = lag
+	f.errs[db] = err
+}
+
+func (f *fakeLagProbe) probe(_ context.Context, db *gorm.DB) (time.Duration, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.lags[db], f.errs[db]
+}
+
+func newFakeLagProbe() *fakeLagProbe {
+	return &fakeLagProbe{lags: map[*gorm.DB]time.Duration{}, errs: map[*gorm.DB]error{}}
+}
+
+func TestPostgresProbe(t *testing.T) {
+	tests := map[string]struct {
+		setupMock func(mock sqlmock.Sqlmock)
+		wantLag   time.Duration
+		wantErr   error
+	}{
+		"success": {
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(`pg_last_xact_replay_timestamp()`)).
+					WillReturnRows(sqlmock.NewRows([]string{"streaming", "lag"}).AddRow(true, 1.5))
+			},
+			wantLag: 1500 * time.Millisecond,
+		},
+		"failure: wal receiver disconnected": {
+			setupMock: func(mock sqlmock.Sqlmock) {
+				// nothing left to replay looks like no lag, but nothing is received either
+				mock.ExpectQuery(regexp.QuoteMeta(`pg_stat_wal_receiver`)).
+					WillReturnRows(sqlmock.NewRows([]string{"streaming", "lag"}).AddRow(false, 0))
+			},
+			wantErr: constant.ErrReplicationStopped,
+		},
+		"failure: query error": {
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(`pg_last_xact_replay_timestamp()`)).
+					WillReturnError(assert.AnError)
+			},
+			wantErr: assert.AnError,
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			db, mock := setupMockGormDB(t)
+			tc.setupMock(mock)
+
+			got, err := lag.PostgresProbe(context.Background(), db)
+
+			require.Equal(t, tc.wantErr, err)
+			require.Equal(t, tc.wantLag, got)
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestLagMonitor_Acceptable(t *testing.T) {
+	tests := map[string]struct {
+		lag            time.Duration
+		probeErr       error
+		probed         bool
+		ctxMaxLag      *time.Duration
+		wantAcceptable bool
+	}{
+		"not probed yet": {
+			wantAcceptable: true,
+		},
+		"within limit": {
+			probed:         true,
+			lag:            time.Second,
+			wantAcceptable: true,
+		},
+		"over limit": {
+			probed:         true,
+			lag:            3 * time.Second,
+			wantAcceptable: false,
+		},
+		"probe failed": {
+			probed:         true,
+			probeErr:       assert.AnError,
+			wantAcceptable: false,
+		},
+		"context relaxes limit": {
+			probed:         true,
+			lag:            3 * time.Second,
+			ctxMaxLag:      durationPtr(5 * time.Second),
+			wantAcceptable: true,
+		},
+		"context tightens limit": {
+			probed:         true,
+			lag:            time.Second,
+			ctxMaxLag:      durationPtr(100 * time.Millisecond),
+			wantAcceptable: false,
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			replicas, _ := setupTestReplicas(t, 1)
+			probe := newFakeLagProbe()
+			probe.set(replicas[0].DB, tc.lag, tc.probeErr)
+			monitor := lag.NewMonitor(replicas, lag.Config{MaxLag: 2 * time.Second, Probe: probe.probe})
+			if tc.probed {
+				monitor.CheckNow(context.Background())
+			}
+			ctx := context.Background()
+			if tc.ctxMaxLag != nil {
+				ctx = lag.WithMaxLag(ctx, *tc.ctxMaxLag)
+			}
+
+			require.Equal(t, tc.wantAcceptable, monitor.Acceptable(ctx, replicas[0]))
+			require.Equal(t, tc.probed && tc.probeErr == nil, monitor.Status()[0].Measured)
+		})
+	}
+}
+
+func TestLagMonitor_DefaultMaxLag(t *testing.T) {
+	replicas, _ := setupTestReplicas(t, 1)
+	probe := newFakeLagProbe()
+	probe.set(replicas[0].DB, time.Second, nil)
+	monitor := lag.NewMonitor(replicas, lag.Config{Probe: probe.probe})
+	monitor.CheckNow(context.Background())
+
+	require.True(t, monitor.Acceptable(context.Background(), replicas[0]))
+
+	probe.set(replicas[0].DB, 6*time.Second, nil)
+	monitor.CheckNow(context.Background())
+
+	require.False(t, monitor.Acceptable(context.Background(), replicas[0]))
+}
+
+func TestDBProvider_SkipsLaggingReplica(t *testing.T) {
+	writeDB, writeMock := setupMockGormDB(t)
+	replicas, mocks := setupTestReplicas(t, 2)
+	probe := newFakeLagProbe()
+	probe.set(replicas[0].DB, 10*time.Second, nil)
+	probe.set(replicas[1].DB, 0, nil)
+	dbProvider, err := provider.NewDBProviderWithReplicas(writeDB, []*balancer.Replica{replicas[0], replicas[1]},
+		provider.WithMaxReplicationLag(lag.Config{MaxLag: time.Second, Interval: 5 * time.Millisecond, Probe: probe.probe}))
+	require.NoError(t, err)
+	defer dbProvider.Close()
+
+	require.Eventually(t, func() bool {
+		return dbProvider.ReplicationLag()[0].Measured
+	}, time.Second, 5*time.Millisecond)
+
+	mocks[1].ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "user_dummies"`)).
+		WillReturnRows(createDummyUsers(1))
+	mocks[1].ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "user_dummies"`)).
+		WillReturnRows(createDummyUsers(1))
+	for i := 0; i < 2; i++ {
+		var users []UserDummy
+		require.NoError(t, dbProvider.Read.Find(&users).Error())
+	}
+
+	writeMock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "user_dummies"`)).
+		WillReturnRows(createDummyUsers(1))
+	probe.set(replicas[1].DB, 10*time.Second, nil)
+	require.Eventually(t, func() bool {
+		return dbProvider.ReplicationLag()[1].Lag == 10*time.Second
+	}, time.Second, 5*time.Millisecond)
+	var users []UserDummy
+	require.NoError(t, dbProvider.Read.Find(&users).Error())
+
+	mocks[0].ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "user_dummies"`)).
+		WillReturnRows(createDummyUsers(1))
+	ctx := lag.WithMaxLag(context.Background(), time.Minute)
+	require.NoError(t, dbProvider.Read.WithContext(ctx).Find(&users).Error())
+
+	for _, mock := range append(mocks, writeMock) {
+		require.NoError(t, mock.ExpectationsWereMet())
+	}
+}
+
+func durationPtr(d time.Duration) *time.Duration {
+	return &d
+}
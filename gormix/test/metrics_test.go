@@ -0,0 +1,106 @@
+package test
+
+import (
+	"context"
+	"github.com/XuanHieuHo/spread-db/gormix"
+	"github.com/XuanHieuHo/spread-db/gormix/metrics"
+	"github.com/XuanHieuHo/spread-db/gormix/provider"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestDBProvider_Metrics(t *testing.T) {
+	writeDB, writeMock := setupMockGormDB(t)
+	replicas, mocks := setupTestReplicas(t, 2)
+	recorder := metrics.NewMemory()
+	dbProvider, err := provider.NewDBProviderWithReplicas(writeDB, replicas,
+		provider.WithMetrics(metrics.Config{Recorder: recorder, Interval: time.Hour}))
+	require.NoError(t, err)
+
+	mocks[0].ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "user_dummies"`)).WillReturnRows(createDummyUsers(2))
+	mocks[1].ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "user_dummies"`)).WillReturnError(assert.AnError)
+	writeMock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "user_dummies"`)).WillReturnRows(createDummyUsers(1))
+	expectUserInsert(writeMock)
+
+	var users []UserDummy
+	require.NoError(t, dbProvider.Read.Find(&users).Error())
+	require.Equal(t, assert.AnError, dbProvider.Read.Find(&users).Error())
+	require.NoError(t, dbProvider.Read.WithContext(gormix.WithPrimary(context.Background())).Find(&users).Error())
+	require.NoError(t, dbProvider.Write.Create(&UserDummy{Name: "User 1", Email: "Email1@example.com"}).Error())
+	dbProvider.Close()
+
+	queries := recorder.Queries()
+	tests := map[string]struct {
+		labels    metrics.Labels
+		wantCount int64
+		wantErrs  int64
+		wantRows  int64
+	}{
+		"read on replica a": {
+			labels:    metrics.Labels{Role: gormix.RoleRead, Replica: "a", Table: "user_dummies", Operation: "query"},
+			wantCount: 1,
+			wantRows:  2,
+		},
+		"failed read on replica b": {
+			labels:    metrics.Labels{Role: gormix.RoleRead, Replica: "b", Table: "user_dummies", Operation: "query"},
+			wantCount: 1,
+			wantErrs:  1,
+		},
+		"read forced to the primary": {
+			labels:    metrics.Labels{Role: gormix.RoleRead, Replica: "primary", Table: "user_dummies", Operation: "query"},
+			wantCount: 1,
+			wantRows:  1,
+		},
+		"write on the primary": {
+			labels:    metrics.Labels{Role: gormix.RoleWrite, Replica: "primary", Table: "user_dummies", Operation: "create"},
+			wantCount: 1,
+			wantRows:  1,
+		},
+	}
+	require.Len(t, queries, len(tests))
+
+	for scenario, test := range tests {
+		test := test
+		t.Run(scenario, func(t *testing.T) {
+			stats, ok := queries[test.labels]
+			require.True(t, ok)
+			require.Equal(t, test.wantCount, stats.Count)
+			require.Equal(t, test.wantErrs, stats.Errors)
+			require.Equal(t, test.wantRows, stats.RowsAffected)
+			require.Equal(t, test.wantCount, stats.Latency.Count)
+		})
+	}
+
+	for _, pool := range []struct{ role, replica string }{
+		{gormix.RoleWrite, "primary"},
+		{gormix.RoleRead, "a"},
+		{gormix.RoleRead, "b"},
+	} {
+		_, ok := recorder.Pool(pool.role, pool.replica)
+		require.True(t, ok, pool.replica)
+	}
+	require.NoError(t, writeMock.ExpectationsWereMet())
+	for _, mock := range mocks {
+		require.NoError(t, mock.ExpectationsWereMet())
+	}
+}
+
+func TestMemory_LatencyHistogram(t *testing.T) {
+	recorder := metrics.NewMemory(10*time.Millisecond, 100*time.Millisecond)
+	labels := metrics.Labels{Role: gormix.RoleRead, Replica: "a", Table: "cities", Operation: "query"}
+
+	for _, d := range []time.Duration{time.Millisecond, 10 * time.Millisecond, 50 * time.Millisecond, time.Second} {
+		recorder.ObserveQuery(labels, d, 0, nil)
+	}
+
+	histogram := recorder.Queries()[labels].Latency
+	require.Equal(t, []int64{2, 1, 1}, histogram.Counts)
+	require.Equal(t, int64(4), histogram.Count)
+	require.Equal(t, 1061*time.Millisecond, histogram.Sum)
+
+	recorder.Reset()
+	require.Empty(t, recorder.Queries())
+}
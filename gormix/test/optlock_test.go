@@ -0,0 +1,133 @@
+package test
+
+import (
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/XuanHieuHo/spread-db/constant"
+	"github.com/XuanHieuHo/spread-db/gormix"
+	"github.com/XuanHieuHo/spread-db/gormix/provider"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"regexp"
+	"testing"
+)
+
+type Document struct {
+	ID      int64  `gorm:"column:id;type:int64;primaryKey" json:"id"`
+	Title   string `gorm:"column:title;type:varchar(255);not null" json:"title"`
+	Version int64  `gorm:"column:version;type:int64;not null;version" json:"version"`
+}
+
+func setupTestOptLockDB(t *testing.T) (gormix.WriteOnlyDB, sqlmock.Sqlmock) {
+	writeDB, mock := setupMockGormDB(t)
+	replicas, _ := setupTestReplicas(t, 1)
+	dbProvider, err := provider.NewDBProviderWithReplicas(writeDB, replicas, provider.WithOptimisticLocking())
+	require.NoError(t, err)
+	return dbProvider.Write, mock
+}
+
+func TestOptimisticLocking(t *testing.T) {
+	tests := map[string]struct {
+		setupMock   func(mock sqlmock.Sqlmock)
+		run         func(db gormix.WriteOnlyDB, doc *Document) error
+		wantErr     error
+		wantVersion int64
+	}{
+		"save: version checked and bumped": {
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(regexp.QuoteMeta(`UPDATE "documents" SET "title"=$1,"version"=$2 WHERE "id" = $3 AND "documents"."version" = $4`)).
+					WithArgs("Draft 2", 3, 1, 2).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+			run: func(db gormix.WriteOnlyDB, doc *Document) error {
+				doc.Title = "Draft 2"
+				return db.Save(doc).Error()
+			},
+			wantVersion: 3,
+		},
+		"save: stale object is not re-inserted": {
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(regexp.QuoteMeta(`UPDATE "documents" SET "title"=$1,"version"=$2 WHERE "id" = $3 AND "documents"."version" = $4`)).
+					WithArgs("Draft 2", 3, 1, 2).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectRollback()
+			},
+			run: func(db gormix.WriteOnlyDB, doc *Document) error {
+				doc.Title = "Draft 2"
+				return db.Save(doc).Error()
+			},
+			wantErr:     constant.ErrStaleObject,
+			wantVersion: 2,
+		},
+		"updates: version checked and bumped": {
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(regexp.QuoteMeta(`UPDATE "documents" SET "title"=$1,"version"=$2 WHERE "id" = $3 AND "documents"."version" = $4`)).
+					WithArgs("Draft 2", 3, 1, 2).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+			run: func(db gormix.WriteOnlyDB, doc *Document) error {
+				return db.Model(doc).Updates(map[string]interface{}{"title": "Draft 2"}).Error()
+			},
+			wantVersion: 3,
+		},
+		"update: stale object": {
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(regexp.QuoteMeta(`UPDATE "documents" SET "title"=$1,"version"=$2 WHERE "id" = $3 AND "documents"."version" = $4`)).
+					WithArgs("Draft 2", 3, 1, 2).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectRollback()
+			},
+			run: func(db gormix.WriteOnlyDB, doc *Document) error {
+				return db.Model(doc).Update("title", "Draft 2").Error()
+			},
+			wantErr:     constant.ErrStaleObject,
+			wantVersion: 2,
+		},
+		"update: query error is kept": {
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(regexp.QuoteMeta(`UPDATE "documents"`)).
+					WillReturnError(assert.AnError)
+				mock.ExpectRollback()
+			},
+			run: func(db gormix.WriteOnlyDB, doc *Document) error {
+				return db.Model(doc).Update("title", "Draft 2").Error()
+			},
+			wantErr:     assert.AnError,
+			wantVersion: 2,
+		},
+		"bulk update: version not checked": {
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(regexp.QuoteMeta(`UPDATE "documents" SET "title"=$1 WHERE title = $2`)).
+					WithArgs("Draft 2", "Draft 1").
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectCommit()
+			},
+			run: func(db gormix.WriteOnlyDB, doc *Document) error {
+				return db.Model(&Document{}).Where("title = ?", "Draft 1").Update("title", "Draft 2").Error()
+			},
+			wantVersion: 2,
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			db, mock := setupTestOptLockDB(t)
+			tc.setupMock(mock)
+			doc := &Document{ID: 1, Title: "Draft 1", Version: 2}
+
+			err := tc.run(db, doc)
+
+			require.Equal(t, tc.wantErr, err)
+			require.Equal(t, tc.wantVersion, doc.Version)
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
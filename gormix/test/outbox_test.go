@@ -0,0 +1,390 @@
+package test
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/XuanHieuHo/spread-db/constant"
+	"github.com/XuanHieuHo/spread-db/gormix"
+	"github.com/XuanHieuHo/spread-db/gormix/outbox"
+	"github.com/XuanHieuHo/spread-db/gormix/provider"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+var outboxColumns = []string{"id", "topic", "key", "payload", "status", "attempts", "last_error", "available_at", "created_at", "sent_at"}
+
+func TestOutbox_Enqueue(t *testing.T) {
+	tests := map[string]struct {
+		setupMock func(mock sqlmock.Sqlmock)
+		run       func(p *provider.DBProvider, events ...outbox.Event) error
+		wantErr   error
+	}{
+		"success: inside transaction": {
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "outbox_messages" ("topic","key","payload","status","attempts","last_error","available_at","created_at","sent_at") VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9),($10,$11,$12,$13,$14,$15,$16,$17,$18) RETURNING "id"`)).
+					WithArgs("users", "1", []byte(`{"id":1}`), outbox.StatusPending, 0, "", sqlmock.AnyArg(), sqlmock.AnyArg(), nil,
+						"users", "2", []byte(`{"id":2}`), outbox.StatusPending, 0, "", sqlmock.AnyArg(), sqlmock.AnyArg(), nil).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1).AddRow(2))
+				mock.ExpectCommit()
+			},
+			run: func(p *provider.DBProvider, events ...outbox.Event) error {
+				return p.Write.Transaction(func(tx gormix.WriteOnlyDB) error {
+					return outbox.Enqueue(context.Background(), tx, events...)
+				})
+			},
+		},
+		"success: transaction carried by context": {
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "outbox_messages"`)).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1).AddRow(2))
+				mock.ExpectCommit()
+			},
+			run: func(p *provider.DBProvider, events ...outbox.Event) error {
+				return p.Transaction(context.Background(), func(ctx context.Context) error {
+					return outbox.Enqueue(ctx, p.Write, events...)
+				})
+			},
+		},
+		"failure: rolled back with the transaction": {
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "outbox_messages"`)).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1).AddRow(2))
+				mock.ExpectRollback()
+			},
+			run: func(p *provider.DBProvider, events ...outbox.Event) error {
+				return p.Write.Transaction(func(tx gormix.WriteOnlyDB) error {
+					if err := outbox.Enqueue(context.Background(), tx, events...); err != nil {
+						return err
+					}
+					return assert.AnError
+				})
+			},
+			wantErr: assert.AnError,
+		},
+		"failure: outside transaction": {
+			setupMock: func(mock sqlmock.Sqlmock) {},
+			run: func(p *provider.DBProvider, events ...outbox.Event) error {
+				return outbox.Enqueue(context.Background(), p.Write, events...)
+			},
+			wantErr: constant.ErrEnqueueOutsideTransaction,
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			writeDB, mock := setupMockGormDB(t)
+			dbProvider := provider.NewDBProvider(&gorm.DB{}, writeDB)
+			tc.setupMock(mock)
+
+			err := tc.run(dbProvider,
+				outbox.Event{Topic: "users", Key: "1", Payload: []byte(`{"id":1}`)},
+				outbox.Event{Topic: "users", Key: "2", Payload: []byte(`{"id":2}`)},
+			)
+
+			require.Equal(t, tc.wantErr, err)
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func setupTestRelay(t *testing.T, publisher outbox.PublisherFunc, cfg outbox.Config, now time.Time) (*outbox.Relay, sqlmock.Sqlmock) {
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		sqlDB.Close()
+	})
+
+	db, err := gorm.Open(postgres.New(postgres.Config{
+		Conn:       sqlDB,
+		DriverName: "postgres",
+	}), &gorm.Config{NowFunc: func() time.Time { return now }})
+	require.NoError(t, err)
+
+	return outbox.NewRelay(provider.NewDBProvider(&gorm.DB{}, db).Write, publisher, cfg), mock
+}
+
+func outboxRows(now time.Time, attempts int, ids ...int64) *sqlmock.Rows {
+	rows := sqlmock.NewRows(outboxColumns)
+	for _, id := range ids {
+		rows.AddRow(id, "users", "1", []byte(`{"id":1}`), outbox.StatusPending, attempts, "", now, now, nil)
+	}
+	return rows
+}
+
+// expectClaim expects a batch to be locked and leased until leasedUntil.
+func expectClaim(mock sqlmock.Sqlmock, now time.Time, batchSize int, leasedUntil time.Time, rows *sqlmock.Rows, ids ...int64) {
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "outbox_messages" WHERE status = $1 AND available_at <= $2 ORDER BY id LIMIT $3 FOR UPDATE SKIP LOCKED`)).
+		WithArgs(outbox.StatusPending, now, batchSize).
+		WillReturnRows(rows)
+	if len(ids) > 0 {
+		expectLease(mock, leasedUntil, ids...)
+	}
+	mock.ExpectCommit()
+}
+
+func expectLease(mock sqlmock.Sqlmock, until time.Time, ids ...int64) {
+	placeholders := make([]string, 0, len(ids))
+	args := []driver.Value{until}
+	for i, id := range ids {
+		placeholders = append(placeholders, fmt.Sprintf("$%d", i+2))
+		args = append(args, id)
+	}
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "outbox_messages" SET "available_at"=$1 WHERE id IN (` + strings.Join(placeholders, ",") + `)`)).
+		WithArgs(args...).
+		WillReturnResult(sqlmock.NewResult(0, int64(len(ids))))
+}
+
+// expectRelease expects the rest of a batch leased until lease to be handed back at now.
+func expectRelease(mock sqlmock.Sqlmock, now time.Time, lease time.Time, ids ...int64) {
+	placeholders := make([]string, 0, len(ids))
+	args := []driver.Value{now}
+	for i, id := range ids {
+		placeholders = append(placeholders, fmt.Sprintf("$%d", i+2))
+		args = append(args, id)
+	}
+	n := len(ids) + 2
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(fmt.Sprintf(`UPDATE "outbox_messages" SET "available_at"=$1 WHERE id IN (%s) AND status = $%d AND available_at = $%d`, strings.Join(placeholders, ","), n, n+1))).
+		WithArgs(append(args, outbox.StatusPending, lease)...).
+		WillReturnResult(sqlmock.NewResult(0, int64(len(ids))))
+	mock.ExpectCommit()
+}
+
+func expectSent(mock sqlmock.Sqlmock, now time.Time, lease time.Time, id int64, attempts int) *sqlmock.ExpectedExec {
+	mock.ExpectBegin()
+	return mock.ExpectExec(regexp.QuoteMeta(`UPDATE "outbox_messages" SET "attempts"=$1,"last_error"=$2,"sent_at"=$3,"status"=$4 WHERE id IN ($5) AND status = $6 AND available_at = $7`)).
+		WithArgs(attempts, "", now, outbox.StatusSent, id, outbox.StatusPending, lease)
+}
+
+func expectRetry(mock sqlmock.Sqlmock, availableAt time.Time, lease time.Time, id int64, attempts int) *sqlmock.ExpectedExec {
+	mock.ExpectBegin()
+	return mock.ExpectExec(regexp.QuoteMeta(`UPDATE "outbox_messages" SET "attempts"=$1,"available_at"=$2,"last_error"=$3 WHERE id IN ($4) AND status = $5 AND available_at = $6`)).
+		WithArgs(attempts, availableAt, assert.AnError.Error(), id, outbox.StatusPending, lease)
+}
+
+func TestOutbox_RelayNow(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := map[string]struct {
+		attempts    int
+		publishErr  error
+		expectWrite func(mock sqlmock.Sqlmock)
+	}{
+		"published": {
+			expectWrite: func(mock sqlmock.Sqlmock) {
+				expectSent(mock, now, now.Add(10*time.Second), 7, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+		},
+		"failed: retried later": {
+			publishErr: assert.AnError,
+			expectWrite: func(mock sqlmock.Sqlmock) {
+				expectRetry(mock, now.Add(time.Second), now.Add(10*time.Second), 7, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+		},
+		"failed: moved to dead letter": {
+			attempts:   2,
+			publishErr: assert.AnError,
+			expectWrite: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(regexp.QuoteMeta(`UPDATE "outbox_messages" SET "attempts"=$1,"last_error"=$2,"status"=$3 WHERE id IN ($4) AND status = $5 AND available_at = $6`)).
+					WithArgs(3, assert.AnError.Error(), outbox.StatusDead, 7, outbox.StatusPending, now.Add(10*time.Second)).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			var published []outbox.Message
+			relay, mock := setupTestRelay(t, func(ctx context.Context, msg outbox.Message) error {
+				published = append(published, msg)
+				return tc.publishErr
+			}, outbox.Config{BatchSize: 10, MaxAttempts: 3, PublishTimeout: time.Second}, now)
+			expectClaim(mock, now, 10, now.Add(10*time.Second), outboxRows(now, tc.attempts, 7), 7)
+			tc.expectWrite(mock)
+
+			n, err := relay.RelayNow(context.Background())
+
+			require.NoError(t, err)
+			require.Equal(t, 1, n)
+			require.Len(t, published, 1)
+			require.Equal(t, int64(7), published[0].ID)
+			require.Equal(t, []byte(`{"id":1}`), published[0].Payload)
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestOutbox_RelayNow_Backoff(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := map[string]struct {
+		attempts        int
+		wantAvailableAt time.Time
+	}{
+		"first failure":  {attempts: 0, wantAvailableAt: now.Add(time.Second)},
+		"second failure": {attempts: 1, wantAvailableAt: now.Add(2 * time.Second)},
+		"third failure":  {attempts: 2, wantAvailableAt: now.Add(4 * time.Second)},
+		"capped":         {attempts: 3, wantAvailableAt: now.Add(5 * time.Second)},
+		"stays capped":   {attempts: 8, wantAvailableAt: now.Add(5 * time.Second)},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			relay, mock := setupTestRelay(t, func(ctx context.Context, msg outbox.Message) error {
+				return assert.AnError
+			}, outbox.Config{InitialBackoff: time.Second, MaxBackoff: 5 * time.Second}, now)
+			expectClaim(mock, now, 100, now.Add(100*10*time.Second), outboxRows(now, tc.attempts, 7), 7)
+			expectRetry(mock, tc.wantAvailableAt, now.Add(1000*time.Second), 7, tc.attempts+1).WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectCommit()
+
+			_, err := relay.RelayNow(context.Background())
+
+			require.NoError(t, err)
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestOutbox_RelayNow_Batch(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	var published []int64
+	relay, mock := setupTestRelay(t, func(ctx context.Context, msg outbox.Message) error {
+		deadline, ok := ctx.Deadline()
+		require.True(t, ok)
+		require.LessOrEqual(t, time.Until(deadline), 2*time.Second)
+		published = append(published, msg.ID)
+		if msg.ID == 8 {
+			return assert.AnError
+		}
+		return nil
+	}, outbox.Config{BatchSize: 3, PublishTimeout: 2 * time.Second, Lease: time.Minute}, now)
+	expectClaim(mock, now, 3, now.Add(time.Minute), outboxRows(now, 0, 7, 8, 9), 7, 8, 9)
+	// every message gets its own update, so a failure does not undo the others
+	expectSent(mock, now, now.Add(time.Minute), 7, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	expectRetry(mock, now.Add(time.Second), now.Add(time.Minute), 8, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	expectSent(mock, now, now.Add(time.Minute), 9, 1).WillReturnError(assert.AnError)
+	mock.ExpectRollback()
+
+	n, err := relay.RelayNow(context.Background())
+
+	require.ErrorIs(t, err, assert.AnError)
+	require.Equal(t, 3, n)
+	require.Equal(t, []int64{7, 8, 9}, published)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestOutbox_RelayNow_LeaseLost(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	later := now.Add(time.Minute)
+	cfg := outbox.Config{BatchSize: 1, Lease: 10 * time.Second}
+
+	// relay B claims message 7 once the lease of relay A has run out and sends it
+	relayB, mockB := setupTestRelay(t, func(ctx context.Context, msg outbox.Message) error {
+		return nil
+	}, cfg, later)
+	expectClaim(mockB, later, 1, later.Add(10*time.Second), outboxRows(now, 0, 7), 7)
+	expectSent(mockB, later, later.Add(10*time.Second), 7, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mockB.ExpectCommit()
+
+	// relay A is still publishing message 7 meanwhile
+	relayA, mockA := setupTestRelay(t, func(ctx context.Context, msg outbox.Message) error {
+		n, err := relayB.RelayNow(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, 1, n)
+		return nil
+	}, cfg, now)
+	expectClaim(mockA, now, 1, now.Add(10*time.Second), outboxRows(now, 0, 7), 7)
+	expectSent(mockA, now, now.Add(10*time.Second), 7, 1).WillReturnResult(sqlmock.NewResult(0, 0))
+	mockA.ExpectCommit()
+
+	n, err := relayA.RelayNow(context.Background())
+
+	require.ErrorIs(t, err, constant.ErrLeaseLost)
+	require.Equal(t, 1, n)
+	require.NoError(t, mockA.ExpectationsWereMet())
+	require.NoError(t, mockB.ExpectationsWereMet())
+}
+
+func TestOutbox_RelayNow_ClaimFails(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	relay, mock := setupTestRelay(t, func(ctx context.Context, msg outbox.Message) error {
+		require.Fail(t, "nothing was claimed")
+		return nil
+	}, outbox.Config{}, now)
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "outbox_messages"`)).
+		WillReturnError(assert.AnError)
+	mock.ExpectRollback()
+
+	n, err := relay.RelayNow(context.Background())
+
+	require.Equal(t, assert.AnError, err)
+	require.Equal(t, 0, n)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestOutbox_RelayStartStop(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := map[string]struct {
+		publish   func(ctx context.Context) error
+		setupMock func(mock sqlmock.Sqlmock)
+	}{
+		"outcome recorded": {
+			publish: func(ctx context.Context) error {
+				return nil
+			},
+			setupMock: func(mock sqlmock.Sqlmock) {
+				expectClaim(mock, now, 100, now.Add(1000*time.Second), outboxRows(now, 0, 7), 7)
+				expectSent(mock, now, now.Add(1000*time.Second), 7, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+		},
+		"interrupted batch handed back": {
+			publish: func(ctx context.Context) error {
+				<-ctx.Done()
+				return ctx.Err()
+			},
+			setupMock: func(mock sqlmock.Sqlmock) {
+				expectClaim(mock, now, 100, now.Add(1000*time.Second), outboxRows(now, 0, 7, 8), 7, 8)
+				expectRelease(mock, now, now.Add(1000*time.Second), 7, 8)
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			started := make(chan struct{}, 1)
+			relay, mock := setupTestRelay(t, func(ctx context.Context, msg outbox.Message) error {
+				started <- struct{}{}
+				return tc.publish(ctx)
+			}, outbox.Config{Interval: time.Hour}, now)
+			tc.setupMock(mock)
+
+			relay.Start()
+			<-started
+			relay.Stop()
+
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
@@ -0,0 +1,158 @@
+package test
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/XuanHieuHo/spread-db/constant"
+	"github.com/XuanHieuHo/spread-db/gormix/pagination"
+	"github.com/XuanHieuHo/spread-db/gormix/readonly"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"regexp"
+	"testing"
+)
+
+func userRows(ids ...int) *sqlmock.Rows {
+	rows := sqlmock.NewRows([]string{"id", "name", "email"})
+	for _, id := range ids {
+		rows.AddRow(id, "User", "user@example.com")
+	}
+	return rows
+}
+
+func userIDs(users []UserDummy) []int64 {
+	ids := make([]int64, 0, len(users))
+	for _, user := range users {
+		ids = append(ids, user.ID)
+	}
+	return ids
+}
+
+func TestPaginate_ForwardAndBack(t *testing.T) {
+	db, mock, cleanup := setupTestReadDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "user_dummies" ORDER BY "user_dummies"."id" LIMIT $1`)).
+		WithArgs(3).
+		WillReturnRows(userRows(1, 2, 3))
+	first, err := pagination.Paginate[UserDummy](ctx, db, "", 2)
+	require.NoError(t, err)
+	require.Equal(t, []int64{1, 2}, userIDs(first.Items))
+	require.NotEmpty(t, first.NextCursor)
+	require.Empty(t, first.PrevCursor)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "user_dummies" WHERE "user_dummies"."id" > $1 ORDER BY "user_dummies"."id" LIMIT $2`)).
+		WithArgs(2, 3).
+		WillReturnRows(userRows(3))
+	second, err := pagination.Paginate[UserDummy](ctx, db, first.NextCursor, 2)
+	require.NoError(t, err)
+	require.Equal(t, []int64{3}, userIDs(second.Items))
+	require.Empty(t, second.NextCursor)
+	require.NotEmpty(t, second.PrevCursor)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "user_dummies" WHERE "user_dummies"."id" < $1 ORDER BY "user_dummies"."id" DESC LIMIT $2`)).
+		WithArgs(3, 3).
+		WillReturnRows(userRows(2, 1))
+	back, err := pagination.Paginate[UserDummy](ctx, db, second.PrevCursor, 2)
+	require.NoError(t, err)
+	require.Equal(t, []int64{1, 2}, userIDs(back.Items))
+	require.NotEmpty(t, back.NextCursor)
+	require.Empty(t, back.PrevCursor)
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPaginate_MultipleColumnsDescending(t *testing.T) {
+	db, mock, cleanup := setupTestReadDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "user_dummies" WHERE email LIKE $1 ORDER BY "user_dummies"."name" DESC,"user_dummies"."id" DESC LIMIT $2`)).
+		WithArgs("%@example.com", 2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "email"}).
+			AddRow(7, "Bob", "bob@example.com").
+			AddRow(5, "Alice", "alice@example.com"))
+	first, err := pagination.Paginate[UserDummy](ctx, db.Where("email LIKE ?", "%@example.com"), "", 1, pagination.Desc("user_dummies.name"))
+	require.NoError(t, err)
+	require.Equal(t, []int64{7}, userIDs(first.Items))
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "user_dummies" WHERE email LIKE $1 AND ("user_dummies"."name" < $2 OR ("user_dummies"."name" = $3 AND "user_dummies"."id" < $4)) ORDER BY "user_dummies"."name" DESC,"user_dummies"."id" DESC LIMIT $5`)).
+		WithArgs("%@example.com", "Bob", "Bob", int64(7), 2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "email"}).
+			AddRow(5, "Alice", "alice@example.com"))
+	second, err := pagination.Paginate[UserDummy](ctx, db.Where("email LIKE ?", "%@example.com"), first.NextCursor, 1, pagination.Desc("user_dummies.name"))
+	require.NoError(t, err)
+	require.Equal(t, []int64{5}, userIDs(second.Items))
+	require.Empty(t, second.NextCursor)
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPaginate_InvalidInput(t *testing.T) {
+	tests := map[string]struct {
+		cursor   string
+		pageSize int
+		columns  []pagination.Column
+		wantErr  error
+	}{
+		"zero page size": {
+			pageSize: 0,
+			wantErr:  constant.ErrInvalidPageSize,
+		},
+		"cursor not base64": {
+			cursor:   "%%%",
+			pageSize: 10,
+			wantErr:  constant.ErrInvalidCursor,
+		},
+		"cursor with wrong arity": {
+			cursor:   "eyJ2IjpbMSwyXX0",
+			pageSize: 10,
+			wantErr:  constant.ErrInvalidCursor,
+		},
+		"unknown column": {
+			pageSize: 10,
+			columns:  []pagination.Column{pagination.Asc("missing")},
+			wantErr:  constant.ErrUnknownColumn,
+		},
+		"table prefix injection": {
+			pageSize: 10,
+			columns:  []pagination.Column{pagination.Asc("(SELECT 1); DROP TABLE user_dummies; --.name")},
+			wantErr:  constant.ErrUnknownColumn,
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			db, mock, cleanup := setupTestReadDB(t)
+			defer cleanup()
+
+			page, err := pagination.Paginate[UserDummy](context.Background(), db, tc.cursor, tc.pageSize, tc.columns...)
+
+			require.Equal(t, tc.wantErr, err)
+			require.Empty(t, page.Items)
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestPaginate_RunsOneQuery(t *testing.T) {
+	readDB, mock := setupMockGormDB(t)
+	var queries int
+	require.NoError(t, readDB.Callback().Query().Before("gorm:query").Register("test:count", func(*gorm.DB) {
+		queries++
+	}))
+	db := readonly.New(readDB)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "user_dummies" ORDER BY "user_dummies"."id" LIMIT $1`)).
+		WithArgs(3).
+		WillReturnRows(userRows(1))
+	page, err := pagination.Paginate[UserDummy](context.Background(), db, "", 2)
+
+	require.NoError(t, err)
+	require.Equal(t, []int64{1}, userIDs(page.Items))
+	// the schema is resolved without going through the callbacks
+	require.Equal(t, 1, queries)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
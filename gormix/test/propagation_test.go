@@ -0,0 +1,238 @@
+package test
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/XuanHieuHo/spread-db/gormix"
+	"github.com/XuanHieuHo/spread-db/gormix/provider"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"regexp"
+	"testing"
+)
+
+func TestDBProvider_TransactionPropagation(t *testing.T) {
+	insertCity := func(p *provider.DBProvider, ctx context.Context, name string) error {
+		return p.Write.WithContext(ctx).Exec(`INSERT INTO "cities" ("name") VALUES (?)`, name).Error()
+	}
+	expectInsert := func(mock sqlmock.Sqlmock, name string, err error) {
+		exec := mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "cities" ("name") VALUES ($1)`)).WithArgs(name)
+		if err != nil {
+			exec.WillReturnError(err)
+		} else {
+			exec.WillReturnResult(sqlmock.NewResult(1, 1))
+		}
+	}
+
+	tests := map[string]struct {
+		setupMock func(mock sqlmock.Sqlmock)
+		txFunc    func(p *provider.DBProvider) func(ctx context.Context) error
+		wantErr   error
+	}{
+		"success: writes through the context join the transaction": {
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				expectInsert(mock, "Hanoi", nil)
+				expectInsert(mock, "Hue", nil)
+				mock.ExpectCommit()
+			},
+			txFunc: func(p *provider.DBProvider) func(ctx context.Context) error {
+				return func(ctx context.Context) error {
+					if err := insertCity(p, ctx, "Hanoi"); err != nil {
+						return err
+					}
+					return insertCity(p, ctx, "Hue")
+				}
+			},
+		},
+		"failure: joined write failure rolls back the transaction": {
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				expectInsert(mock, "Hanoi", nil)
+				expectInsert(mock, "Hue", assert.AnError)
+				mock.ExpectRollback()
+			},
+			txFunc: func(p *provider.DBProvider) func(ctx context.Context) error {
+				return func(ctx context.Context) error {
+					if err := insertCity(p, ctx, "Hanoi"); err != nil {
+						return err
+					}
+					return insertCity(p, ctx, "Hue")
+				}
+			},
+			wantErr: assert.AnError,
+		},
+		"success: required inner transaction uses a savepoint": {
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				expectInsert(mock, "Hanoi", nil)
+				mock.ExpectExec(`SAVEPOINT sp\d+`).WillReturnResult(sqlmock.NewResult(0, 0))
+				expectInsert(mock, "Hue", assert.AnError)
+				mock.ExpectExec(`ROLLBACK TO SAVEPOINT sp\d+`).WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectCommit()
+			},
+			txFunc: func(p *provider.DBProvider) func(ctx context.Context) error {
+				return func(ctx context.Context) error {
+					if err := insertCity(p, ctx, "Hanoi"); err != nil {
+						return err
+					}
+					err := p.Transaction(ctx, func(ctx context.Context) error {
+						return insertCity(p, ctx, "Hue")
+					})
+					if err != assert.AnError {
+						return err
+					}
+					return nil
+				}
+			},
+		},
+		"success: requires new begins an independent transaction": {
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				expectInsert(mock, "Hanoi", nil)
+				mock.ExpectBegin()
+				expectInsert(mock, "Hue", nil)
+				mock.ExpectCommit()
+				mock.ExpectCommit()
+			},
+			txFunc: func(p *provider.DBProvider) func(ctx context.Context) error {
+				return func(ctx context.Context) error {
+					if err := insertCity(p, ctx, "Hanoi"); err != nil {
+						return err
+					}
+					ctx = gormix.WithPropagation(ctx, gormix.PropagationRequiresNew)
+					return p.Transaction(ctx, func(ctx context.Context) error {
+						return insertCity(p, ctx, "Hue")
+					})
+				}
+			},
+		},
+		"success: requires new commits even when the outer transaction rolls back": {
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectBegin()
+				expectInsert(mock, "Hue", nil)
+				mock.ExpectCommit()
+				mock.ExpectRollback()
+			},
+			txFunc: func(p *provider.DBProvider) func(ctx context.Context) error {
+				return func(ctx context.Context) error {
+					err := p.Transaction(gormix.WithPropagation(ctx, gormix.PropagationRequiresNew), func(ctx context.Context) error {
+						return insertCity(p, ctx, "Hue")
+					})
+					if err != nil {
+						return err
+					}
+					return assert.AnError
+				}
+			},
+			wantErr: assert.AnError,
+		},
+	}
+
+	for scenario, test := range tests {
+		test := test
+		t.Run(scenario, func(t *testing.T) {
+			writeDB, mock := setupMockGormDB(t)
+			p := provider.NewDBProvider(&gorm.DB{}, writeDB)
+			test.setupMock(mock)
+
+			err := p.Transaction(context.Background(), test.txFunc(p))
+			require.Equal(t, test.wantErr, err)
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestWriteDB_TransactionStashesContext(t *testing.T) {
+	db, mock, cleanup := setupTestWriteDB(t)
+	defer cleanup()
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+
+	_, ok := gormix.TxFromContext(context.Background())
+	require.False(t, ok)
+
+	err := db.WithContext(context.Background()).Transaction(func(tx gormix.WriteOnlyDB) error {
+		stashed, ok := gormix.TxFromContext(tx.Statement().Context)
+		require.True(t, ok)
+		require.Equal(t, tx.Statement().ConnPool, stashed.Statement().ConnPool)
+		return nil
+	})
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestWriteDB_WithContextKeepsChain(t *testing.T) {
+	expectFind := func(mock sqlmock.Sqlmock) {
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "user_dummies" WHERE name = $1`)).
+			WithArgs("tenant-a").
+			WillReturnRows(createDummyUsers(1))
+	}
+	expectDelete := func(mock sqlmock.Sqlmock) {
+		mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "user_dummies" WHERE name = $1`)).
+			WithArgs("tenant-a").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+
+	tests := map[string]struct {
+		setupMock func(mock sqlmock.Sqlmock)
+		run       func(p *provider.DBProvider, chain func(ctx context.Context) error) error
+	}{
+		"no transaction": {
+			setupMock: func(mock sqlmock.Sqlmock) {
+				expectFind(mock)
+				mock.ExpectBegin()
+				expectDelete(mock)
+				mock.ExpectCommit()
+			},
+			run: func(p *provider.DBProvider, chain func(ctx context.Context) error) error {
+				return chain(context.Background())
+			},
+		},
+		"joined transaction": {
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				expectFind(mock)
+				expectDelete(mock)
+				mock.ExpectCommit()
+			},
+			run: func(p *provider.DBProvider, chain func(ctx context.Context) error) error {
+				return p.Transaction(context.Background(), chain)
+			},
+		},
+		"requires new": {
+			setupMock: func(mock sqlmock.Sqlmock) {
+				expectFind(mock)
+				mock.ExpectBegin()
+				expectDelete(mock)
+				mock.ExpectCommit()
+			},
+			run: func(p *provider.DBProvider, chain func(ctx context.Context) error) error {
+				return chain(gormix.WithPropagation(context.Background(), gormix.PropagationRequiresNew))
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			writeDB, mock := setupMockGormDB(t)
+			dbProvider := provider.NewDBProvider(&gorm.DB{}, writeDB)
+			tc.setupMock(mock)
+
+			err := tc.run(dbProvider, func(ctx context.Context) error {
+				scoped := dbProvider.Write.Where("name = ?", "tenant-a")
+				var users []UserDummy
+				if err := scoped.WithContext(ctx).Find(&users).Error(); err != nil {
+					return err
+				}
+				return scoped.WithContext(ctx).Delete(&UserDummy{}).Error()
+			})
+
+			require.NoError(t, err)
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
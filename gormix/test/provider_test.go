@@ -0,0 +1,214 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/XuanHieuHo/spread-db/constant"
+	"github.com/XuanHieuHo/spread-db/gormix"
+	"github.com/XuanHieuHo/spread-db/gormix/balancer"
+	"github.com/XuanHieuHo/spread-db/gormix/provider"
+	"github.com/XuanHieuHo/spread-db/gormix/slowlog"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"regexp"
+	"testing"
+)
+
+func setupMockGormDB(t *testing.T) (*gorm.DB, sqlmock.Sqlmock) {
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		sqlDB.Close()
+	})
+
+	db, err := gorm.Open(postgres.New(postgres.Config{
+		Conn:       sqlDB,
+		DriverName: "postgres",
+	}), &gorm.Config{})
+	require.NoError(t, err)
+
+	return db, mock
+}
+
+func setupTestReplicas(t *testing.T, quantity int) ([]*balancer.Replica, []sqlmock.Sqlmock) {
+	replicas := make([]*balancer.Replica, 0, quantity)
+	mocks := make([]sqlmock.Sqlmock, 0, quantity)
+	for i := 0; i < quantity; i++ {
+		db, mock := setupMockGormDB(t)
+		replicas = append(replicas, balancer.NewReplica(string(rune('a'+i)), db, 1))
+		mocks = append(mocks, mock)
+	}
+	return replicas, mocks
+}
+
+func TestNewDBProviderWithReplicas_NoReplicas(t *testing.T) {
+	writeDB, _ := setupMockGormDB(t)
+
+	dbProvider, err := provider.NewDBProviderWithReplicas(writeDB, nil)
+
+	require.Nil(t, dbProvider)
+	require.Equal(t, constant.ErrNoReplicas, err)
+}
+
+// warnLogger collects the warnings gorm logs, such as duplicated callbacks.
+type warnLogger struct {
+	logger.Interface
+	warnings []string
+}
+
+func (l *warnLogger) LogMode(logger.LogLevel) logger.Interface {
+	return l
+}
+
+func (l *warnLogger) Warn(_ context.Context, msg string, args ...interface{}) {
+	l.warnings = append(l.warnings, fmt.Sprintf(msg, args...))
+}
+
+func TestNewDBProviderWithReplicas_Retry(t *testing.T) {
+	log := &warnLogger{Interface: logger.Discard}
+	open := func() *gorm.DB {
+		sqlDB, _, err := sqlmock.New()
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			sqlDB.Close()
+		})
+		db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB, DriverName: "postgres"}), &gorm.Config{Logger: log})
+		require.NoError(t, err)
+		return db
+	}
+	writeDB := open()
+	replicas := []*balancer.Replica{balancer.NewReplica("a", open(), 1)}
+	opts := []provider.Option{
+		provider.WithReadOnlyGuard(),
+		provider.WithOptimisticLocking(),
+		provider.WithSlowQueryLog(slowlog.Config{}),
+	}
+
+	// a retry finds the plugins of the first attempt in place
+	for attempt := 0; attempt < 2; attempt++ {
+		_, err := provider.NewDBProviderWithReplicas(writeDB, replicas, opts...)
+		require.NoError(t, err)
+	}
+
+	require.Empty(t, log.warnings)
+}
+
+func TestDBProvider_ReadRoundRobin(t *testing.T) {
+	writeDB, writeMock := setupMockGormDB(t)
+	replicas, mocks := setupTestReplicas(t, 3)
+	dbProvider, err := provider.NewDBProviderWithReplicas(writeDB, replicas)
+	require.NoError(t, err)
+
+	for round := 0; round < 2; round++ {
+		for _, mock := range mocks {
+			mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "user_dummies"`)).
+				WillReturnRows(createDummyUsers(1))
+		}
+	}
+
+	for i := 0; i < 6; i++ {
+		var users []UserDummy
+		require.NoError(t, dbProvider.Read.Find(&users).Error())
+		require.Len(t, users, 1)
+	}
+
+	for _, mock := range mocks {
+		require.NoError(t, mock.ExpectationsWereMet())
+	}
+	require.NoError(t, writeMock.ExpectationsWereMet())
+}
+
+func TestDBProvider_ReadGettersDoNotPickReplica(t *testing.T) {
+	writeDB, _ := setupMockGormDB(t)
+	replicas, mocks := setupTestReplicas(t, 2)
+	dbProvider, err := provider.NewDBProviderWithReplicas(writeDB, replicas)
+	require.NoError(t, err)
+
+	mocks[0].ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "user_dummies"`)).
+		WillReturnRows(createDummyUsers(1))
+
+	require.NoError(t, dbProvider.Read.Error())
+	require.Equal(t, "postgres", dbProvider.Read.Dialector().Name())
+	require.NotNil(t, dbProvider.Read.Statement())
+	var users []UserDummy
+	require.NoError(t, dbProvider.Read.Find(&users).Error())
+
+	for _, mock := range mocks {
+		require.NoError(t, mock.ExpectationsWereMet())
+	}
+}
+
+func TestDBProvider_ReadChainSticksToReplica(t *testing.T) {
+	writeDB, _ := setupMockGormDB(t)
+	replicas, mocks := setupTestReplicas(t, 3)
+	dbProvider, err := provider.NewDBProviderWithReplicas(writeDB, replicas)
+	require.NoError(t, err)
+
+	mocks[0].ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "user_dummies" WHERE id > $1`)).
+		WithArgs(0).
+		WillReturnRows(createDummyUsers(2))
+	mocks[0].ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "user_dummies" WHERE id > $1`)).
+		WithArgs(0).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+
+	chain := dbProvider.Read.WithContext(context.Background()).Model(&UserDummy{}).Where("id > ?", 0)
+	var users []UserDummy
+	var count int64
+	require.NoError(t, chain.Find(&users).Error())
+	require.NoError(t, chain.Count(&count).Error())
+
+	require.Len(t, users, 2)
+	require.Equal(t, int64(2), count)
+	for _, mock := range mocks {
+		require.NoError(t, mock.ExpectationsWereMet())
+	}
+}
+
+func TestDBProvider_ReadWithPrimary(t *testing.T) {
+	tests := map[string]struct {
+		newProvider func(t *testing.T, writeDB *gorm.DB) (*provider.DBProvider, []sqlmock.Sqlmock)
+	}{
+		"single read pool": {
+			newProvider: func(t *testing.T, writeDB *gorm.DB) (*provider.DBProvider, []sqlmock.Sqlmock) {
+				readDB, readMock := setupMockGormDB(t)
+				return provider.NewDBProvider(readDB, writeDB), []sqlmock.Sqlmock{readMock}
+			},
+		},
+		"replicas": {
+			newProvider: func(t *testing.T, writeDB *gorm.DB) (*provider.DBProvider, []sqlmock.Sqlmock) {
+				replicas, mocks := setupTestReplicas(t, 2)
+				dbProvider, err := provider.NewDBProviderWithReplicas(writeDB, replicas)
+				require.NoError(t, err)
+				return dbProvider, mocks
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			writeDB, writeMock := setupMockGormDB(t)
+			dbProvider, readMocks := tc.newProvider(t, writeDB)
+			ctx := gormix.WithPrimary(context.Background())
+
+			writeMock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "user_dummies" WHERE id = $1 LIMIT $2`)).
+				WithArgs(1, 1).
+				WillReturnRows(createDummyUsers(1))
+
+			var user UserDummy
+			require.NoError(t, dbProvider.Read.WithContext(ctx).Where("id = ?", 1).Take(&user).Error())
+			require.Equal(t, int64(1), user.ID)
+
+			err := dbProvider.Read.WithContext(ctx).Raw("DELETE FROM user_dummies").Scan(&user).Error()
+			require.Equal(t, constant.ErrWriteOperationOnReadDB, err)
+
+			require.NoError(t, writeMock.ExpectationsWereMet())
+			for _, mock := range readMocks {
+				require.NoError(t, mock.ExpectationsWereMet())
+			}
+		})
+	}
+}
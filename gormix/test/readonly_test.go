@@ -3,8 +3,10 @@ package test
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
 	"fmt"
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/XuanHieuHo/spread-db/constant"
 	"github.com/XuanHieuHo/spread-db/gormix"
 	"github.com/XuanHieuHo/spread-db/gormix/provider"
 	"github.com/stretchr/testify/assert"
@@ -12,6 +14,7 @@ import (
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
+	"reflect"
 	"regexp"
 	"strings"
 	"testing"
@@ -401,7 +404,7 @@ func TestReadDB_Having(t *testing.T) {
 				Find(&[]UserDummy{}).
 				Statement()
 
-			sql := statement.SQL.String()
+			sql := statement.SQL
 			require.Contains(t, sql, tc.expectedSQL)
 			require.Equal(t, tc.args.args, statement.Vars)
 		})
@@ -451,7 +454,6 @@ func TestReadDB_OrderBy(t *testing.T) {
 			// then
 			require.NoError(t, result.Error())
 			statement := result.Statement()
-			statement.Scopes()
 
 			clauseOrder, ok := statement.Clauses["ORDER BY"].Expression.(clause.OrderBy)
 			require.True(t, ok, "ORDER BY clause not found")
@@ -498,7 +500,7 @@ func TestReadDB_LimitOffset(t *testing.T) {
 		t.Run(name, func(t *testing.T) {
 			db, _, _ := setupTestReadDB(t)
 
-			var statement *gorm.Statement
+			var statement gormix.StatementView
 			if tc.args.args != nil {
 				limit := tc.args.args[0].(int)
 				offset := tc.args.args[1].(int)
@@ -510,7 +512,7 @@ func TestReadDB_LimitOffset(t *testing.T) {
 					Statement()
 			}
 
-			sql := statement.SQL.String()
+			sql := statement.SQL
 			require.Contains(t, sql, tc.expectedSQL)
 			require.Equal(t, tc.args.args, statement.Vars)
 		})
@@ -553,7 +555,7 @@ func TestReadDB_Scopes(t *testing.T) {
 				Find(&[]UserDummy{}).
 				Statement()
 
-			sql := statement.SQL.String()
+			sql := statement.SQL
 			require.Contains(t, sql, tc.expectedSQL)
 		})
 	}
@@ -579,15 +581,14 @@ func TestReadDB_Unscoped(t *testing.T) {
 		t.Run(name, func(t *testing.T) {
 			db, _, _ := setupTestReadDB(t)
 
-			var statement *gorm.Statement
+			var statement gormix.StatementView
 			if tc.unscoped {
 				statement = db.Session(&gorm.Session{DryRun: true}).Unscoped().Find(&[]City{}).Statement()
 			} else {
 				statement = db.Session(&gorm.Session{DryRun: true}).Find(&[]City{}).Statement()
 			}
 
-			sql := statement.SQL.String()
-			fmt.Println("----------------", sql)
+			sql := statement.SQL
 			if tc.unscoped {
 				require.NotContains(t, sql, tc.expectedCon)
 			} else {
@@ -628,7 +629,6 @@ func TestReadDB_Preload(t *testing.T) {
 				Preload(tc.preload, tc.args.query, tc.args.args).
 				Find(&[]UserDummy{}).Statement()
 			preload := statement.Preloads
-			fmt.Println("-----------", preload)
 
 			for key, value := range preload {
 				require.Equal(t, key, tc.preload)
@@ -639,6 +639,49 @@ func TestReadDB_Preload(t *testing.T) {
 	}
 }
 
+func TestReadDB_PreloadCallback(t *testing.T) {
+	tests := map[string]struct {
+		callback  interface{}
+		setupMock func(mock sqlmock.Sqlmock)
+		wantErr   error
+	}{
+		"success: read-only callback": {
+			callback: func(tx gormix.ReadOnlyDB) gormix.ReadOnlyDB {
+				return tx.Where("price > ?", 10)
+			},
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "user_dummies"`)).
+					WillReturnRows(createDummyUsers(1))
+				mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "orders" WHERE price > $1 AND "orders"."user_id" = $2`)).
+					WithArgs(10, 1).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "price"}).AddRow(1, 1, 20))
+			},
+		},
+		"failure: gorm callback would get a writable connection": {
+			callback: func(tx *gorm.DB) *gorm.DB {
+				return tx.Session(&gorm.Session{NewDB: true}).Exec("DELETE FROM user_dummies")
+			},
+			setupMock: func(mock sqlmock.Sqlmock) {},
+			wantErr:   constant.ErrWriteOperationOnReadDB,
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			db, mock, cleanup := setupTestReadDB(t)
+			defer cleanup()
+			tc.setupMock(mock)
+
+			var users []UserDummy
+			err := db.Preload("Orders", tc.callback).Find(&users).Error()
+
+			require.Equal(t, tc.wantErr, err)
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
 func TestReadDB_Distinct(t *testing.T) {
 	tests := map[string]struct {
 		distinct bool
@@ -689,7 +732,7 @@ func TestReadDB_Omit(t *testing.T) {
 				Find(&[]UserDummy{}).
 				Statement()
 
-			sql := statement.SQL.String()
+			sql := statement.SQL
 
 			require.Equal(t, sql, tc.expected)
 			for _, field := range tc.omitFields {
@@ -726,7 +769,7 @@ func TestReadDB_Raw(t *testing.T) {
 
 			statement := query.Statement()
 
-			sql := statement.SQL.String()
+			sql := statement.SQL
 			require.Equal(t, sql, tc.expectedSQL)
 			require.Equal(t, statement.Vars, tc.args)
 		})
@@ -825,7 +868,7 @@ func TestReadDB_Find(t *testing.T) {
 			statement := checkQuery.Statement()
 			dialector := checkQuery.Dialector()
 
-			sql := statement.SQL.String()
+			sql := statement.SQL
 			vars := statement.Vars
 
 			if test.wantErr {
@@ -1465,3 +1508,570 @@ func TestReadDB_Rows(t *testing.T) {
 		})
 	}
 }
+
+func TestReadDB_RawWriteGuard(t *testing.T) {
+	tests := map[string]struct {
+		rawQuery string
+		wantErr  error
+	}{
+		"select":                        {rawQuery: "SELECT * FROM user_dummies WHERE id = ?"},
+		"lowercase select":              {rawQuery: "select id from user_dummies"},
+		"parenthesized union":           {rawQuery: "(SELECT id FROM a) UNION (SELECT id FROM b)"},
+		"read-only cte":                 {rawQuery: "WITH recent AS (SELECT * FROM orders) SELECT * FROM recent"},
+		"explain select":                {rawQuery: "EXPLAIN SELECT * FROM user_dummies"},
+		"show":                          {rawQuery: "SHOW server_version"},
+		"values":                        {rawQuery: "VALUES (1), (2)"},
+		"keyword inside string":         {rawQuery: "SELECT * FROM logs WHERE action = 'DELETE FROM users; DROP TABLE x'"},
+		"keyword inside quoted ident":   {rawQuery: `SELECT "update", "insert" FROM audit`},
+		"keyword inside line comment":   {rawQuery: "SELECT 1 -- DELETE FROM users\n"},
+		"keyword inside block comment":  {rawQuery: "SELECT /* UPDATE users SET x = 1 */ 1"},
+		"keyword inside dollar quote":   {rawQuery: "SELECT $body$ DELETE FROM users $body$"},
+		"positional parameters":         {rawQuery: "SELECT * FROM user_dummies WHERE id = $1 AND name = $2"},
+		"column prefixed by keyword":    {rawQuery: "SELECT updated_at, deleted_at, created_by FROM user_dummies"},
+		"substring for":                 {rawQuery: "SELECT substring(name FROM 1 FOR 3) FROM user_dummies"},
+		"trailing semicolon":            {rawQuery: "SELECT 1;"},
+		"insert":                        {rawQuery: "INSERT INTO user_dummies (name) VALUES ('a')", wantErr: constant.ErrWriteOperationOnReadDB},
+		"update":                        {rawQuery: "update user_dummies set name = 'a'", wantErr: constant.ErrWriteOperationOnReadDB},
+		"delete":                        {rawQuery: "DELETE FROM user_dummies", wantErr: constant.ErrWriteOperationOnReadDB},
+		"ddl":                           {rawQuery: "CREATE TABLE t (id int)", wantErr: constant.ErrWriteOperationOnReadDB},
+		"truncate":                      {rawQuery: "TRUNCATE user_dummies", wantErr: constant.ErrWriteOperationOnReadDB},
+		"cte with insert":               {rawQuery: "WITH moved AS (INSERT INTO archive SELECT * FROM orders RETURNING *) SELECT * FROM moved", wantErr: constant.ErrWriteOperationOnReadDB},
+		"cte with delete":               {rawQuery: "WITH gone AS (DELETE FROM orders RETURNING id) SELECT count(*) FROM gone", wantErr: constant.ErrWriteOperationOnReadDB},
+		"select for update":             {rawQuery: "SELECT * FROM orders WHERE id = 1 FOR UPDATE", wantErr: constant.ErrLockOnReadDB},
+		"select for no key update":      {rawQuery: "SELECT * FROM orders FOR NO KEY UPDATE SKIP LOCKED", wantErr: constant.ErrLockOnReadDB},
+		"select for share":              {rawQuery: "SELECT * FROM orders FOR SHARE", wantErr: constant.ErrLockOnReadDB},
+		"select for update of":          {rawQuery: "SELECT * FROM orders o JOIN users u ON u.id = o.user_id FOR UPDATE OF o", wantErr: constant.ErrLockOnReadDB},
+		"lock then write":               {rawQuery: "SELECT * FROM orders FOR UPDATE; UPDATE orders SET price = 0", wantErr: constant.ErrWriteOperationOnReadDB},
+		"select into":                   {rawQuery: "SELECT * INTO backup FROM orders", wantErr: constant.ErrWriteOperationOnReadDB},
+		"explain analyze delete":        {rawQuery: "EXPLAIN ANALYZE DELETE FROM orders", wantErr: constant.ErrWriteOperationOnReadDB},
+		"copy":                          {rawQuery: "COPY orders FROM '/tmp/orders.csv'", wantErr: constant.ErrWriteOperationOnReadDB},
+		"copy to stdout":                {rawQuery: "COPY orders TO STDOUT", wantErr: constant.ErrWriteOperationOnReadDB},
+		"call":                          {rawQuery: "CALL refresh_stats()", wantErr: constant.ErrWriteOperationOnReadDB},
+		"do block":                      {rawQuery: "DO $$ BEGIN DELETE FROM orders; END $$", wantErr: constant.ErrWriteOperationOnReadDB},
+		"multi statement":               {rawQuery: "SELECT 1; DELETE FROM orders", wantErr: constant.ErrWriteOperationOnReadDB},
+		"multi statement after comment": {rawQuery: "SELECT 1 /* ; */; DROP TABLE orders", wantErr: constant.ErrWriteOperationOnReadDB},
+		"escaped quote then write":      {rawQuery: "SELECT 'it''s'; UPDATE orders SET price = 0", wantErr: constant.ErrWriteOperationOnReadDB},
+		"escape string then write":      {rawQuery: `SELECT E'\''; DELETE FROM orders; --'`, wantErr: constant.ErrWriteOperationOnReadDB},
+		"leading comment":               {rawQuery: "/* report */ DELETE FROM orders", wantErr: constant.ErrWriteOperationOnReadDB},
+		"set":                           {rawQuery: "SET TRANSACTION READ WRITE", wantErr: constant.ErrWriteOperationOnReadDB},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			db, mock, cleanup := setupTestReadDB(t)
+			defer cleanup()
+
+			err := db.Raw(tc.rawQuery).Error()
+
+			require.Equal(t, tc.wantErr, err)
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestReadDB_RawWriteGuardNeverReachesDriver(t *testing.T) {
+	db, mock, cleanup := setupTestReadDB(t)
+	defer cleanup()
+
+	var users []UserDummy
+	err := db.Raw("DELETE FROM user_dummies RETURNING *").Scan(&users).Error()
+	require.Equal(t, constant.ErrWriteOperationOnReadDB, err)
+
+	rows, err := db.Raw("WITH d AS (DELETE FROM user_dummies RETURNING id) SELECT * FROM d").Rows()
+	require.Nil(t, rows)
+	require.Equal(t, constant.ErrWriteOperationOnReadDB, err)
+
+	var id int64
+	err = db.Raw("UPDATE user_dummies SET name = 'x' RETURNING id").Row().Scan(&id)
+	require.Equal(t, constant.ErrWriteOperationOnReadDB, err)
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+var writableTypes = []reflect.Type{
+	reflect.TypeOf(&gorm.DB{}),
+	reflect.TypeOf(gorm.DB{}),
+	reflect.TypeOf(&gorm.Statement{}),
+	reflect.TypeOf(&sql.DB{}),
+	reflect.TypeOf(&sql.Tx{}),
+	reflect.TypeOf(&sql.Conn{}),
+}
+
+// findWritable walks the exported parts of v, i.e. everything a caller can reach
+// without reflection or unsafe, and returns the path to the first value whose type
+// can issue writes.
+func findWritable(v reflect.Value, path string, seen map[uintptr]bool) string {
+	if !v.IsValid() {
+		return ""
+	}
+	for _, typ := range writableTypes {
+		if v.Type() == typ {
+			return path
+		}
+	}
+	if v.Type() == reflect.TypeOf((*reflect.Type)(nil)).Elem() || v.Type() == reflect.TypeOf(reflect.Value{}) {
+		return ""
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice:
+		if v.IsNil() {
+			return ""
+		}
+		if v.Kind() != reflect.Slice {
+			if seen[v.Pointer()] {
+				return ""
+			}
+			seen[v.Pointer()] = true
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		return findWritable(v.Elem(), path, seen)
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if !v.Type().Field(i).IsExported() {
+				continue
+			}
+			if found := findWritable(v.Field(i), path+"."+v.Type().Field(i).Name, seen); found != "" {
+				return found
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if found := findWritable(v.Index(i), fmt.Sprintf("%s[%d]", path, i), seen); found != "" {
+				return found
+			}
+		}
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			if found := findWritable(iter.Value(), fmt.Sprintf("%s[%v]", path, iter.Key()), seen); found != "" {
+				return found
+			}
+		}
+	}
+	return ""
+}
+
+func TestReadDB_NoWritableDBReachable(t *testing.T) {
+	tests := map[string]func(db gormix.ReadOnlyDB) gormix.ReadOnlyDB{
+		"root": func(db gormix.ReadOnlyDB) gormix.ReadOnlyDB {
+			return db
+		},
+		"built query": func(db gormix.ReadOnlyDB) gormix.ReadOnlyDB {
+			return db.Session(&gorm.Session{DryRun: true}).
+				Model(&UserDummy{}).
+				Joins("INNER JOIN orders ON orders.user_id = user_dummies.id").
+				Preload("Orders", "price > ?", 10).
+				Where("name = ?", "Alice").
+				Order("id").
+				Find(&[]UserDummy{})
+		},
+		"raw query": func(db gormix.ReadOnlyDB) gormix.ReadOnlyDB {
+			return db.Session(&gorm.Session{DryRun: true}).Raw("SELECT * FROM user_dummies WHERE id = ?", 1).Scan(&[]UserDummy{})
+		},
+	}
+
+	for name, build := range tests {
+		build := build
+		t.Run(name, func(t *testing.T) {
+			db, _, cleanup := setupTestReadDB(t)
+			defer cleanup()
+
+			result := build(db)
+
+			seen := map[uintptr]bool{}
+			require.Empty(t, findWritable(reflect.ValueOf(result.Statement()), "Statement()", seen))
+			require.Empty(t, findWritable(reflect.ValueOf(result.Dialector()), "Dialector()", seen))
+			_, isGormDialector := result.Dialector().(gorm.Dialector)
+			require.False(t, isGormDialector)
+		})
+	}
+}
+
+func TestReadDB_SessionRejectsWriteOptions(t *testing.T) {
+	tests := map[string]struct {
+		session *gorm.Session
+		wantErr error
+	}{
+		"nil session":                {session: nil},
+		"dry run":                    {session: &gorm.Session{DryRun: true}},
+		"new db with context":        {session: &gorm.Session{NewDB: true, Context: context.Background()}},
+		"prepare statements":         {session: &gorm.Session{PrepareStmt: true}},
+		"allow global update":        {session: &gorm.Session{AllowGlobalUpdate: true}, wantErr: constant.ErrWriteSessionOnReadDB},
+		"full save associations":     {session: &gorm.Session{FullSaveAssociations: true}, wantErr: constant.ErrWriteSessionOnReadDB},
+		"skip default transaction":   {session: &gorm.Session{SkipDefaultTransaction: true}, wantErr: constant.ErrWriteSessionOnReadDB},
+		"disable nested transaction": {session: &gorm.Session{DisableNestedTransaction: true}, wantErr: constant.ErrWriteSessionOnReadDB},
+		"create batch size":          {session: &gorm.Session{CreateBatchSize: 100}, wantErr: constant.ErrWriteSessionOnReadDB},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			db, mock, cleanup := setupTestReadDB(t)
+			defer cleanup()
+
+			result := db.Session(tc.session)
+			err := result.Find(&[]UserDummy{}).Error()
+
+			if tc.wantErr != nil {
+				require.Equal(t, tc.wantErr, err)
+				require.NoError(t, mock.ExpectationsWereMet())
+			} else {
+				require.NoError(t, result.Error())
+			}
+		})
+	}
+}
+
+// txOptionsPool records the options of every transaction it begins.
+type txOptionsPool struct {
+	*sql.DB
+	opts []*sql.TxOptions
+}
+
+func (p *txOptionsPool) BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error) {
+	p.opts = append(p.opts, opts)
+	return p.DB.BeginTx(ctx, opts)
+}
+
+func setupTestReadTxDB(t *testing.T) (gormix.ReadOnlyDB, sqlmock.Sqlmock, *txOptionsPool) {
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		sqlDB.Close()
+	})
+
+	pool := &txOptionsPool{DB: sqlDB}
+	db, err := gorm.Open(postgres.New(postgres.Config{
+		Conn:       pool,
+		DriverName: "postgres",
+	}), &gorm.Config{})
+	require.NoError(t, err)
+
+	return provider.NewDBProvider(db, &gorm.DB{}).Read, mock, pool
+}
+
+func TestReadDB_Transaction(t *testing.T) {
+	tests := map[string]struct {
+		opts      []*sql.TxOptions
+		setupMock func(mock sqlmock.Sqlmock)
+		txFunc    func(tx gormix.ReadOnlyDB) error
+		wantOpts  []*sql.TxOptions
+		wantErr   error
+	}{
+		"success: read only by default": {
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				expectUserSelect(mock)
+				expectUserSelect(mock)
+				mock.ExpectCommit()
+			},
+			txFunc: func(tx gormix.ReadOnlyDB) error {
+				var first, second []UserDummy
+				if err := tx.Find(&first).Error(); err != nil {
+					return err
+				}
+				return tx.Find(&second).Error()
+			},
+			wantOpts: []*sql.TxOptions{{ReadOnly: true}},
+		},
+		"success: nested transaction joins the outer one": {
+			opts: []*sql.TxOptions{{Isolation: sql.LevelRepeatableRead, ReadOnly: true}},
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				expectUserSelect(mock)
+				mock.ExpectCommit()
+			},
+			txFunc: func(tx gormix.ReadOnlyDB) error {
+				return tx.Transaction(func(inner gormix.ReadOnlyDB) error {
+					var users []UserDummy
+					return inner.Find(&users).Error()
+				})
+			},
+			wantOpts: []*sql.TxOptions{{Isolation: sql.LevelRepeatableRead, ReadOnly: true}},
+		},
+		"success: nested snapshot in a snapshot": {
+			opts: []*sql.TxOptions{{Isolation: sql.LevelRepeatableRead, ReadOnly: true}},
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				expectUserSelect(mock)
+				mock.ExpectCommit()
+			},
+			txFunc: func(tx gormix.ReadOnlyDB) error {
+				return tx.Where("1 = 1").ReadSnapshot(func(inner gormix.ReadOnlyDB) error {
+					var users []UserDummy
+					return inner.Find(&users).Error()
+				})
+			},
+			wantOpts: []*sql.TxOptions{{Isolation: sql.LevelRepeatableRead, ReadOnly: true}},
+		},
+		"failure: nested snapshot in a weaker transaction": {
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectRollback()
+			},
+			txFunc: func(tx gormix.ReadOnlyDB) error {
+				return tx.ReadSnapshot(func(inner gormix.ReadOnlyDB) error {
+					return nil
+				})
+			},
+			wantOpts: []*sql.TxOptions{{ReadOnly: true}},
+			wantErr:  constant.ErrNestedIsolation,
+		},
+		"failure: callback error rolls back": {
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectRollback()
+			},
+			txFunc: func(tx gormix.ReadOnlyDB) error {
+				return assert.AnError
+			},
+			wantOpts: []*sql.TxOptions{{ReadOnly: true}},
+			wantErr:  assert.AnError,
+		},
+		"failure: raw write inside transaction": {
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectRollback()
+			},
+			txFunc: func(tx gormix.ReadOnlyDB) error {
+				return tx.Raw(`DELETE FROM "user_dummies"`).Scan(&[]UserDummy{}).Error()
+			},
+			wantOpts: []*sql.TxOptions{{ReadOnly: true}},
+			wantErr:  constant.ErrWriteOperationOnReadDB,
+		},
+		"failure: writable options refused": {
+			opts:      []*sql.TxOptions{{Isolation: sql.LevelSerializable}},
+			setupMock: func(mock sqlmock.Sqlmock) {},
+			txFunc: func(tx gormix.ReadOnlyDB) error {
+				return nil
+			},
+			wantErr: constant.ErrWriteTransactionOnReadDB,
+		},
+	}
+
+	for scenario, test := range tests {
+		test := test
+		t.Run(scenario, func(t *testing.T) {
+			db, mock, pool := setupTestReadTxDB(t)
+			test.setupMock(mock)
+
+			err := db.Transaction(test.txFunc, test.opts...)
+			require.Equal(t, test.wantErr, err)
+			require.Equal(t, test.wantOpts, pool.opts)
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestReadDB_ReadSnapshot(t *testing.T) {
+	tests := map[string]struct {
+		opts      []gormix.SnapshotOptions
+		setupMock func(mock sqlmock.Sqlmock)
+		wantOpts  []*sql.TxOptions
+	}{
+		"success: repeatable read": {
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				expectUserSelect(mock)
+				mock.ExpectCommit()
+			},
+			wantOpts: []*sql.TxOptions{{Isolation: sql.LevelRepeatableRead, ReadOnly: true}},
+		},
+		"success: deferrable": {
+			opts: []gormix.SnapshotOptions{{Deferrable: true}},
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(regexp.QuoteMeta(`SET TRANSACTION DEFERRABLE`)).WillReturnResult(sqlmock.NewResult(0, 0))
+				expectUserSelect(mock)
+				mock.ExpectCommit()
+			},
+			wantOpts: []*sql.TxOptions{{Isolation: sql.LevelSerializable, ReadOnly: true}},
+		},
+	}
+
+	for scenario, test := range tests {
+		test := test
+		t.Run(scenario, func(t *testing.T) {
+			db, mock, pool := setupTestReadTxDB(t)
+			test.setupMock(mock)
+
+			err := db.ReadSnapshot(func(tx gormix.ReadOnlyDB) error {
+				var users []UserDummy
+				return tx.Find(&users).Error()
+			}, test.opts...)
+			require.NoError(t, err)
+			require.Equal(t, test.wantOpts, pool.opts)
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestReadDB_OrNot(t *testing.T) {
+	tests := map[string]struct {
+		build   func(db gormix.ReadOnlyDB) gormix.ReadOnlyDB
+		wantSQL string
+		args    []driver.Value
+	}{
+		"or": {
+			build: func(db gormix.ReadOnlyDB) gormix.ReadOnlyDB {
+				return db.Where("name = ?", "Alice").Or("name = ?", "Bob")
+			},
+			wantSQL: `SELECT * FROM "user_dummies" WHERE name = $1 OR name = $2`,
+			args:    []driver.Value{"Alice", "Bob"},
+		},
+		"not": {
+			build: func(db gormix.ReadOnlyDB) gormix.ReadOnlyDB {
+				return db.Not("name = ?", "Alice")
+			},
+			wantSQL: `SELECT * FROM "user_dummies" WHERE NOT name = $1`,
+			args:    []driver.Value{"Alice"},
+		},
+		"not map": {
+			build: func(db gormix.ReadOnlyDB) gormix.ReadOnlyDB {
+				return db.Not(map[string]interface{}{"name": []string{"Alice", "Bob"}})
+			},
+			wantSQL: `SELECT * FROM "user_dummies" WHERE "name" NOT IN ($1,$2)`,
+			args:    []driver.Value{"Alice", "Bob"},
+		},
+		"grouped conditions": {
+			build: func(db gormix.ReadOnlyDB) gormix.ReadOnlyDB {
+				return db.Where(db.Where("name = ?", "Alice").Or("email = ?", "alice@example.com")).
+					Not("id = ?", 3)
+			},
+			wantSQL: `SELECT * FROM "user_dummies" WHERE (name = $1 OR email = $2) AND NOT id = $3`,
+			args:    []driver.Value{"Alice", "alice@example.com", 3},
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			db, mock, cleanup := setupTestReadDB(t)
+			defer cleanup()
+			mock.ExpectQuery(regexp.QuoteMeta(tc.wantSQL)).
+				WithArgs(tc.args...).
+				WillReturnRows(createDummyUsers(1))
+
+			var users []UserDummy
+			require.NoError(t, tc.build(db).Find(&users).Error())
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestReadDB_InnerJoins(t *testing.T) {
+	db, _, cleanup := setupTestReadDB(t)
+	defer cleanup()
+
+	result := db.Model(&UserDummy{}).InnerJoins("Orders")
+
+	require.NoError(t, result.Error())
+	statement := result.Statement()
+	require.Len(t, statement.Joins, 1)
+	require.Equal(t, "Orders", statement.Joins[0].Name)
+	require.Equal(t, clause.InnerJoin, statement.Joins[0].JoinType)
+}
+
+func TestReadDB_Clauses(t *testing.T) {
+	tests := map[string]struct {
+		conds   []clause.Expression
+		wantErr error
+	}{
+		"success: where expression": {
+			conds: []clause.Expression{clause.Where{Exprs: []clause.Expression{clause.Eq{Column: "name", Value: "Alice"}}}},
+		},
+		"success: order by": {
+			conds: []clause.Expression{clause.OrderBy{Columns: []clause.OrderByColumn{{Column: clause.Column{Name: "id"}}}}},
+		},
+		"failure: for update": {
+			conds:   []clause.Expression{clause.Locking{Strength: clause.LockingStrengthUpdate}},
+			wantErr: constant.ErrLockOnReadDB,
+		},
+		"failure: for share pointer": {
+			conds:   []clause.Expression{&clause.Locking{Strength: clause.LockingStrengthShare}},
+			wantErr: constant.ErrLockOnReadDB,
+		},
+		"failure: returning": {
+			conds:   []clause.Expression{clause.Returning{}},
+			wantErr: constant.ErrWriteClauseOnReadDB,
+		},
+		"failure: on conflict": {
+			conds:   []clause.Expression{clause.OnConflict{DoNothing: true}},
+			wantErr: constant.ErrWriteClauseOnReadDB,
+		},
+		"failure: set": {
+			conds:   []clause.Expression{clause.Set{{Column: clause.Column{Name: "name"}, Value: "Bob"}}},
+			wantErr: constant.ErrWriteClauseOnReadDB,
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			db, mock, cleanup := setupTestReadDB(t)
+			defer cleanup()
+			if tc.wantErr == nil {
+				mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "user_dummies"`)).WillReturnRows(createDummyUsers(1))
+			}
+
+			var users []UserDummy
+			err := db.Clauses(tc.conds...).Find(&users).Error()
+			require.Equal(t, tc.wantErr, err)
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestReadDB_FirstOrInit(t *testing.T) {
+	tests := map[string]struct {
+		rows     *sqlmock.Rows
+		wantUser UserDummy
+	}{
+		"found": {
+			rows:     createDummyUsers(1),
+			wantUser: UserDummy{ID: 1, Name: "User 1", Email: "Email1@example.com"},
+		},
+		"initialized from conditions": {
+			rows:     sqlmock.NewRows([]string{"id", "name", "email"}),
+			wantUser: UserDummy{Name: "User 1"},
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			db, mock, cleanup := setupTestReadDB(t)
+			defer cleanup()
+			mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "user_dummies" WHERE "user_dummies"."name" = $1 ORDER BY "user_dummies"."id" LIMIT $2`)).
+				WithArgs("User 1", 1).
+				WillReturnRows(tc.rows)
+
+			var user UserDummy
+			require.NoError(t, db.FirstOrInit(&user, UserDummy{Name: "User 1"}).Error())
+			require.Equal(t, tc.wantUser, user)
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestReadDB_Connection(t *testing.T) {
+	db, mock, cleanup := setupTestReadDB(t)
+	defer cleanup()
+	expectUserSelect(mock)
+	expectUserSelect(mock)
+
+	err := db.Connection(func(tx gormix.ReadOnlyDB) error {
+		var first, second []UserDummy
+		if err := tx.Find(&first).Error(); err != nil {
+			return err
+		}
+		return tx.Find(&second).Error()
+	})
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
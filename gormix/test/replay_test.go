@@ -0,0 +1,175 @@
+package test
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/XuanHieuHo/spread-db/gormix"
+	"github.com/XuanHieuHo/spread-db/gormix/provider"
+	"github.com/XuanHieuHo/spread-db/gormix/replay"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"regexp"
+	"testing"
+)
+
+func setupReplayMock(t *testing.T, name string) (dsnConnector, sqlmock.Sqlmock) {
+	mockDB, mock, err := sqlmock.NewWithDSN(name)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		mockDB.Close()
+	})
+	return dsnConnector{dsn: name, drv: mockDB.Driver()}, mock
+}
+
+// runReplayScenario issues the statements recorded by TestReplay_RecordThenReplay.
+func runReplayScenario(t *testing.T, dbProvider *provider.DBProvider) {
+	var users []UserDummy
+	require.NoError(t, dbProvider.Read.Where("name = ?", "User 1").Find(&users).Error())
+	require.Equal(t, "Email1@example.com", users[0].Email)
+
+	err := dbProvider.Write.Transaction(func(tx gormix.WriteOnlyDB) error {
+		return tx.Exec(`UPDATE "cities" SET "name" = ? WHERE id = ?`, "Hue", 1).Error()
+	})
+	require.NoError(t, err)
+
+	var count int64
+	require.Equal(t, assert.AnError.Error(), dbProvider.Read.Model(&City{}).Count(&count).Error().Error())
+}
+
+func TestReplay_RecordThenReplay(t *testing.T) {
+	golden := filepath.Join(t.TempDir(), "replay.json")
+
+	t.Run("record", func(t *testing.T) {
+		read, readMock := setupReplayMock(t, "replay_read_"+t.Name())
+		write, writeMock := setupReplayMock(t, "replay_write_"+t.Name())
+		readMock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "user_dummies" WHERE name = $1`)).
+			WithArgs("User 1").
+			WillReturnRows(createDummyUsers(1))
+		writeMock.ExpectBegin()
+		writeMock.ExpectExec(regexp.QuoteMeta(`UPDATE "cities" SET "name" = $1 WHERE id = $2`)).
+			WithArgs("Hue", 1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		writeMock.ExpectCommit()
+		readMock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "cities" WHERE "cities"."deleted_at" IS NULL`)).
+			WillReturnError(assert.AnError)
+
+		harness := replay.New(t, golden, replay.Config{Mode: replay.ModeRecord})
+		runReplayScenario(t, harness.DBProvider(read, write))
+		require.NoError(t, readMock.ExpectationsWereMet())
+		require.NoError(t, writeMock.ExpectationsWereMet())
+	})
+
+	t.Run("replay", func(t *testing.T) {
+		harness := replay.New(t, golden, replay.Config{Mode: replay.ModeReplay})
+		runReplayScenario(t, harness.DBProvider(nil, nil))
+	})
+}
+
+func TestReplay_Drift(t *testing.T) {
+	golden := filepath.Join(t.TempDir(), "replay.json")
+	t.Run("record", func(t *testing.T) {
+		read, readMock := setupReplayMock(t, "replay_drift_"+t.Name())
+		readMock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "user_dummies" WHERE name = $1`)).
+			WithArgs("User 1").
+			WillReturnRows(createDummyUsers(1))
+
+		harness := replay.New(t, golden, replay.Config{Mode: replay.ModeRecord})
+		var users []UserDummy
+		require.NoError(t, harness.DBProvider(read, nil).Read.Where("name = ?", "User 1").Find(&users).Error())
+	})
+
+	tests := map[string]struct {
+		run          func(dbProvider *provider.DBProvider) error
+		wantErr      error
+		wantFailures int
+	}{
+		"success: same statement": {
+			run: func(dbProvider *provider.DBProvider) error {
+				return dbProvider.Read.Where("name = ?", "User 1").Find(&[]UserDummy{}).Error()
+			},
+		},
+		"failure: different args": {
+			run: func(dbProvider *provider.DBProvider) error {
+				return dbProvider.Read.Where("name = ?", "User 2").Find(&[]UserDummy{}).Error()
+			},
+			wantErr: replay.ErrDrift,
+			// the drifted statement and the one left unissued
+			wantFailures: 2,
+		},
+		"failure: different sql": {
+			run: func(dbProvider *provider.DBProvider) error {
+				return dbProvider.Read.WithContext(context.Background()).Where("email = ?", "User 1").Find(&[]UserDummy{}).Error()
+			},
+			wantErr:      replay.ErrDrift,
+			wantFailures: 2,
+		},
+		"failure: statement not issued": {
+			run: func(dbProvider *provider.DBProvider) error {
+				return nil
+			},
+			wantFailures: 1,
+		},
+	}
+
+	for scenario, test := range tests {
+		test := test
+		t.Run(scenario, func(t *testing.T) {
+			tb := &recordingTB{TB: t}
+			t.Run("replay", func(t *testing.T) {
+				tb.TB = t
+				harness := replay.New(tb, golden, replay.Config{Mode: replay.ModeReplay})
+				require.Equal(t, test.wantErr, test.run(harness.DBProvider(nil, nil)))
+			})
+			require.Len(t, tb.failures, test.wantFailures, tb.failures)
+		})
+	}
+}
+
+func TestReplay_Matchers(t *testing.T) {
+	golden := filepath.Join(t.TempDir(), "replay.json")
+	// gorm stamps updated_at from the clock, so every run issues a different one
+	update := func(dbProvider *provider.DBProvider) error {
+		return dbProvider.Write.Model(&Animal{ID: 1}).Update("name", "Cat").Error()
+	}
+	t.Run("record", func(t *testing.T) {
+		write, writeMock := setupReplayMock(t, "replay_matchers_"+t.Name())
+		writeMock.ExpectBegin()
+		writeMock.ExpectExec(regexp.QuoteMeta(`UPDATE "animals" SET "name"=$1,"updated_at"=$2 WHERE "id" = $3`)).
+			WithArgs("Cat", sqlmock.AnyArg(), 1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		writeMock.ExpectCommit()
+
+		harness := replay.New(t, golden, replay.Config{Mode: replay.ModeRecord})
+		require.NoError(t, update(harness.DBProvider(nil, write)))
+		require.NoError(t, writeMock.ExpectationsWereMet())
+	})
+
+	tests := map[string]struct {
+		matchers     []replay.Matcher
+		wantErr      error
+		wantFailures int
+	}{
+		"success: any time": {
+			matchers: []replay.Matcher{replay.AnyTime},
+		},
+		"failure: exact": {
+			wantErr: replay.ErrDrift,
+			// the drifted update, the rollback that follows it and the commit left unissued
+			wantFailures: 3,
+		},
+	}
+
+	for scenario, test := range tests {
+		test := test
+		t.Run(scenario, func(t *testing.T) {
+			tb := &recordingTB{TB: t}
+			t.Run("replay", func(t *testing.T) {
+				tb.TB = t
+				harness := replay.New(tb, golden, replay.Config{Mode: replay.ModeReplay, Matchers: test.matchers})
+				require.ErrorIs(t, update(harness.DBProvider(nil, nil)), test.wantErr)
+			})
+			require.Len(t, tb.failures, test.wantFailures, tb.failures)
+		})
+	}
+}
@@ -0,0 +1,196 @@
+package test
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/XuanHieuHo/spread-db/gormix/provider"
+	"github.com/XuanHieuHo/spread-db/gormix/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"regexp"
+	"testing"
+)
+
+func setupTestRepository(t *testing.T) (*repository.Repository[UserDummy], sqlmock.Sqlmock, sqlmock.Sqlmock) {
+	readDB, readMock := setupMockGormDB(t)
+	writeDB, writeMock := setupMockGormDB(t)
+	return repository.New[UserDummy](provider.NewDBProvider(readDB, writeDB)), readMock, writeMock
+}
+
+func TestRepository_Get(t *testing.T) {
+	tests := map[string]struct {
+		setupMock  func(mock sqlmock.Sqlmock)
+		wantErr    error
+		wantResult UserDummy
+	}{
+		"success": {
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "user_dummies" WHERE "user_dummies"."id" = $1 LIMIT $2`)).
+					WithArgs(1, 1).
+					WillReturnRows(createDummyUsers(1))
+			},
+			wantResult: UserDummy{ID: 1, Name: "User 1", Email: "Email1@example.com"},
+		},
+		"failure: not found": {
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "user_dummies" WHERE "user_dummies"."id" = $1 LIMIT $2`)).
+					WithArgs(1, 1).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "name", "email"}))
+			},
+			wantErr: gorm.ErrRecordNotFound,
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			repo, readMock, writeMock := setupTestRepository(t)
+			tc.setupMock(readMock)
+
+			user, err := repo.Get(context.Background(), 1)
+
+			require.Equal(t, tc.wantErr, err)
+			require.Equal(t, tc.wantResult, user)
+			require.NoError(t, readMock.ExpectationsWereMet())
+			require.NoError(t, writeMock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestRepository_List(t *testing.T) {
+	tests := map[string]struct {
+		filters    []repository.Filter
+		setupMock  func(mock sqlmock.Sqlmock)
+		wantErr    error
+		wantResult []UserDummy
+	}{
+		"no filter": {
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "user_dummies"`)).
+					WillReturnRows(createDummyUsers(2))
+			},
+			wantResult: []UserDummy{
+				{ID: 1, Name: "User 1", Email: "Email1@example.com"},
+				{ID: 2, Name: "User 2", Email: "Email2@example.com"},
+			},
+		},
+		"filters": {
+			filters: []repository.Filter{repository.Where("id > ?", 0), repository.Order("id desc"), repository.Limit(1)},
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "user_dummies" WHERE id > $1 ORDER BY id desc LIMIT $2`)).
+					WithArgs(0, 1).
+					WillReturnRows(createDummyUsers(1))
+			},
+			wantResult: []UserDummy{{ID: 1, Name: "User 1", Email: "Email1@example.com"}},
+		},
+		"empty result": {
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "user_dummies"`)).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "name", "email"}))
+			},
+			wantResult: []UserDummy{},
+		},
+		"failure: query error": {
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "user_dummies"`)).
+					WillReturnError(assert.AnError)
+			},
+			wantErr:    assert.AnError,
+			wantResult: []UserDummy{},
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			repo, readMock, _ := setupTestRepository(t)
+			tc.setupMock(readMock)
+
+			users, err := repo.List(context.Background(), tc.filters...)
+
+			require.Equal(t, tc.wantErr, err)
+			require.Equal(t, tc.wantResult, users)
+			require.NoError(t, readMock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestRepository_ExistsAndCount(t *testing.T) {
+	repo, readMock, _ := setupTestRepository(t)
+	readMock.ExpectQuery(regexp.QuoteMeta(`SELECT 1 FROM "user_dummies" WHERE email = $1 LIMIT $2`)).
+		WithArgs("Email1@example.com", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"?column?"}).AddRow(1))
+	readMock.ExpectQuery(regexp.QuoteMeta(`SELECT 1 FROM "user_dummies" WHERE email = $1 LIMIT $2`)).
+		WithArgs("missing@example.com", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"?column?"}))
+	readMock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "user_dummies" WHERE id > $1`)).
+		WithArgs(0).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(7))
+
+	exists, err := repo.Exists(context.Background(), repository.Where("email = ?", "Email1@example.com"))
+	require.NoError(t, err)
+	require.True(t, exists)
+
+	exists, err = repo.Exists(context.Background(), repository.Where("email = ?", "missing@example.com"))
+	require.NoError(t, err)
+	require.False(t, exists)
+
+	count, err := repo.Count(context.Background(), repository.Where("id > ?", 0))
+	require.NoError(t, err)
+	require.Equal(t, int64(7), count)
+
+	require.NoError(t, readMock.ExpectationsWereMet())
+}
+
+func TestRepository_StringIDIsBound(t *testing.T) {
+	repo, readMock, writeMock := setupTestRepository(t)
+	readMock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "user_dummies" WHERE "user_dummies"."id" = $1 LIMIT $2`)).
+		WithArgs("1=1 OR name <> ''", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "email"}))
+	writeMock.ExpectBegin()
+	writeMock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "user_dummies" WHERE "user_dummies"."id" = $1`)).
+		WithArgs("1=1").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	writeMock.ExpectCommit()
+
+	_, err := repo.Get(context.Background(), "1=1 OR name <> ''")
+	require.Equal(t, gorm.ErrRecordNotFound, err)
+	require.NoError(t, repo.Delete(context.Background(), "1=1"))
+
+	require.NoError(t, readMock.ExpectationsWereMet())
+	require.NoError(t, writeMock.ExpectationsWereMet())
+}
+
+func TestRepository_Writes(t *testing.T) {
+	repo, readMock, writeMock := setupTestRepository(t)
+	writeMock.ExpectBegin()
+	writeMock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "user_dummies" ("name","email") VALUES ($1,$2) RETURNING "id"`)).
+		WithArgs("User 1", "Email1@example.com").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	writeMock.ExpectCommit()
+	writeMock.ExpectBegin()
+	writeMock.ExpectExec(regexp.QuoteMeta(`UPDATE "user_dummies" SET "name"=$1,"email"=$2 WHERE "id" = $3`)).
+		WithArgs("User 2", "Email1@example.com", 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	writeMock.ExpectCommit()
+	writeMock.ExpectBegin()
+	writeMock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "user_dummies" WHERE "user_dummies"."id" = $1`)).
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	writeMock.ExpectCommit()
+
+	created, err := repo.Create(context.Background(), UserDummy{Name: "User 1", Email: "Email1@example.com"})
+	require.NoError(t, err)
+	require.Equal(t, UserDummy{ID: 1, Name: "User 1", Email: "Email1@example.com"}, created)
+
+	created.Name = "User 2"
+	updated, err := repo.Update(context.Background(), created)
+	require.NoError(t, err)
+	require.Equal(t, "User 2", updated.Name)
+
+	require.NoError(t, repo.Delete(context.Background(), 1))
+
+	require.NoError(t, writeMock.ExpectationsWereMet())
+	require.NoError(t, readMock.ExpectationsWereMet())
+}
@@ -0,0 +1,161 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/XuanHieuHo/spread-db/gormix"
+	"github.com/XuanHieuHo/spread-db/gormix/retry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"regexp"
+	"testing"
+	"time"
+)
+
+type sqlStateError struct {
+	code string
+}
+
+func (e *sqlStateError) Error() string {
+	return "ERROR: could not serialize access (SQLSTATE " + e.code + ")"
+}
+
+func (e *sqlStateError) SQLState() string {
+	return e.code
+}
+
+func expectCityInsert(mock sqlmock.Sqlmock, err error) {
+	mock.ExpectBegin()
+	exec := mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "cities" ("name") VALUES ($1)`)).WithArgs("Hanoi")
+	if err != nil {
+		exec.WillReturnError(err)
+		mock.ExpectRollback()
+		return
+	}
+	exec.WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+}
+
+func TestRetry_Transaction(t *testing.T) {
+	serialization := &sqlStateError{code: "40001"}
+	deadlock := &sqlStateError{code: "40P01"}
+
+	tests := map[string]struct {
+		policy      retry.Policy
+		setupMock   func(mock sqlmock.Sqlmock)
+		wantErr     error
+		wantRetries []error
+	}{
+		"success: first attempt": {
+			setupMock: func(mock sqlmock.Sqlmock) {
+				expectCityInsert(mock, nil)
+			},
+		},
+		"success: serialization failure and deadlock retried": {
+			setupMock: func(mock sqlmock.Sqlmock) {
+				expectCityInsert(mock, serialization)
+				expectCityInsert(mock, deadlock)
+				expectCityInsert(mock, nil)
+			},
+			wantRetries: []error{serialization, deadlock},
+		},
+		"failure: attempts exhausted": {
+			policy: retry.Policy{MaxAttempts: 2},
+			setupMock: func(mock sqlmock.Sqlmock) {
+				expectCityInsert(mock, serialization)
+				expectCityInsert(mock, serialization)
+			},
+			wantErr:     serialization,
+			wantRetries: []error{serialization},
+		},
+		"failure: non-retryable error": {
+			setupMock: func(mock sqlmock.Sqlmock) {
+				expectCityInsert(mock, assert.AnError)
+			},
+			wantErr: assert.AnError,
+		},
+		"success: custom classifier": {
+			policy: retry.Policy{
+				IsRetryable: func(err error) bool {
+					return errors.Is(err, assert.AnError)
+				},
+			},
+			setupMock: func(mock sqlmock.Sqlmock) {
+				expectCityInsert(mock, assert.AnError)
+				expectCityInsert(mock, nil)
+			},
+			wantRetries: []error{assert.AnError},
+		},
+	}
+
+	for scenario, test := range tests {
+		test := test
+		t.Run(scenario, func(t *testing.T) {
+			db, mock, cleanup := setupTestWriteDB(t)
+			defer cleanup()
+			test.setupMock(mock)
+
+			var retries []error
+			policy := test.policy
+			policy.InitialBackoff = time.Millisecond
+			policy.OnRetry = func(ctx context.Context, attempt int, err error, delay time.Duration) {
+				require.Equal(t, len(retries)+1, attempt)
+				require.LessOrEqual(t, delay, time.Second)
+				retries = append(retries, err)
+			}
+
+			err := retry.Transaction(context.Background(), db, policy, func(tx gormix.WriteOnlyDB) error {
+				return tx.Exec(`INSERT INTO "cities" ("name") VALUES (?)`, "Hanoi").Error()
+			})
+			require.Equal(t, test.wantErr, err)
+			require.Equal(t, test.wantRetries, retries)
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestRetry_TransactionStopsOnContextCancel(t *testing.T) {
+	db, mock, cleanup := setupTestWriteDB(t)
+	defer cleanup()
+	expectCityInsert(mock, &sqlStateError{code: "40001"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	policy := retry.Policy{
+		InitialBackoff: time.Hour,
+		MaxBackoff:     time.Hour,
+		OnRetry: func(context.Context, int, error, time.Duration) {
+			cancel()
+		},
+	}
+	err := retry.Transaction(ctx, db, policy, func(tx gormix.WriteOnlyDB) error {
+		return tx.Exec(`INSERT INTO "cities" ("name") VALUES (?)`, "Hanoi").Error()
+	})
+	require.Equal(t, context.Canceled, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRetry_Classifiers(t *testing.T) {
+	tests := map[string]struct {
+		dialect string
+		err     error
+		want    bool
+	}{
+		"postgres serialization failure": {dialect: "postgres", err: &sqlStateError{code: "40001"}, want: true},
+		"postgres deadlock":              {dialect: "postgres", err: &sqlStateError{code: "40P01"}, want: true},
+		"postgres unique violation":      {dialect: "postgres", err: &sqlStateError{code: "23505"}, want: false},
+		"postgres plain error":           {dialect: "postgres", err: assert.AnError, want: false},
+		"mysql deadlock":                 {dialect: "mysql", err: errors.New("Error 1213 (40001): Deadlock found when trying to get lock"), want: true},
+		"mysql lock wait timeout":        {dialect: "mysql", err: errors.New("Error 1205 (HY000): Lock wait timeout exceeded"), want: true},
+		"mysql duplicate entry":          {dialect: "mysql", err: errors.New("Error 1062 (23000): Duplicate entry"), want: false},
+		"sqlite busy":                    {dialect: "sqlite", err: errors.New("database is locked (5) (SQLITE_BUSY)"), want: true},
+		"unknown dialect":                {dialect: "oracle", err: &sqlStateError{code: "40001"}, want: false},
+	}
+
+	for scenario, test := range tests {
+		test := test
+		t.Run(scenario, func(t *testing.T) {
+			require.Equal(t, test.want, retry.ClassifierFor(test.dialect)(test.err))
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package test
+
+import (
+	"github.com/XuanHieuHo/spread-db/gormix"
+	"github.com/XuanHieuHo/spread-db/gormix/provider"
+	"github.com/XuanHieuHo/spread-db/gormix/slowlog"
+	"github.com/XuanHieuHo/spread-db/gormix/sqlnorm"
+	"github.com/stretchr/testify/require"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDBProvider_SlowQueryLog(t *testing.T) {
+	writeDB, writeMock := setupMockGormDB(t)
+	replicas, mocks := setupTestReplicas(t, 1)
+	var entries []slowlog.Entry
+	dbProvider, err := provider.NewDBProviderWithReplicas(writeDB, replicas, provider.WithSlowQueryLog(slowlog.Config{
+		Threshold: 20 * time.Millisecond,
+		Handler: func(entry slowlog.Entry) {
+			entries = append(entries, entry)
+		},
+	}))
+	require.NoError(t, err)
+
+	mocks[0].ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "user_dummies" WHERE "user_dummies"."id" IN ($1,$2,$3)`)).
+		WithArgs(1, 2, 3).
+		WillDelayFor(30 * time.Millisecond).
+		WillReturnRows(createDummyUsers(3))
+	writeMock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "user_dummies"`)).WillReturnRows(createDummyUsers(1))
+
+	var users []UserDummy
+	require.NoError(t, dbProvider.Read.Find(&users, []int{1, 2, 3}).Error())
+	require.NoError(t, dbProvider.Write.Find(&users).Error())
+
+	require.Len(t, entries, 1)
+	entry := entries[0]
+	require.Equal(t, `SELECT * FROM "user_dummies" WHERE "user_dummies"."id" IN (...)`, entry.Fingerprint)
+	require.Equal(t, gormix.RoleRead, entry.Role)
+	require.Equal(t, "a", entry.Replica)
+	require.Equal(t, int64(3), entry.Rows)
+	require.GreaterOrEqual(t, entry.Duration, 30*time.Millisecond)
+	require.True(t, strings.Contains(entry.Caller, "slowlog_test.go:"), entry.Caller)
+	require.NoError(t, writeMock.ExpectationsWereMet())
+	require.NoError(t, mocks[0].ExpectationsWereMet())
+}
+
+func TestSqlnorm_Fingerprint(t *testing.T) {
+	tests := map[string]struct {
+		dialect string
+		queries []string
+		want    string
+	}{
+		"literals and placeholders": {
+			dialect: "postgres",
+			queries: []string{
+				`SELECT * FROM users WHERE id = 1 AND name = 'a'`,
+				`SELECT * FROM users WHERE id = $1 AND name = $2`,
+				"SELECT *  FROM users\n\tWHERE id = ? AND name = ?",
+			},
+			want: `SELECT * FROM users WHERE id = ? AND name = ?`,
+		},
+		"in lists collapsed": {
+			dialect: "postgres",
+			queries: []string{
+				`SELECT * FROM users WHERE id IN (1, 2, 3)`,
+				`SELECT * FROM users WHERE id in ($1,$2)`,
+				`SELECT * FROM users WHERE id IN (?)`,
+			},
+			want: `SELECT * FROM users WHERE id IN (...)`,
+		},
+		"dollar tags": {
+			dialect: "postgres",
+			queries: []string{
+				`SELECT * FROM docs WHERE body = $$a 'quoted' body$$`,
+				`SELECT * FROM docs WHERE body = $tag1$other$tag1$`,
+				`SELECT * FROM docs WHERE body = $_t$x$$y$_t$`,
+			},
+			want: `SELECT * FROM docs WHERE body = ?`,
+		},
+		"hex and exponent literals": {
+			dialect: "postgres",
+			queries: []string{
+				`SELECT * FROM blobs WHERE hash = 0xDEAD AND score > 1.5e-3`,
+				`SELECT * FROM blobs WHERE hash = 0x1f AND score > 42`,
+			},
+			want: `SELECT * FROM blobs WHERE hash = ? AND score > ?`,
+		},
+		"comments": {
+			dialect: "postgres",
+			queries: []string{
+				`SELECT * FROM users /* outer /* nested */ still comment */ WHERE id = 1`,
+				"SELECT * FROM users -- trailing 'quote\nWHERE id = 2",
+				`SELECT * FROM users WHERE id = 3`,
+			},
+			want: `SELECT * FROM users WHERE id = ?`,
+		},
+		"mysql comments": {
+			dialect: "mysql",
+			queries: []string{
+				"SELECT * FROM users # 'secret\nWHERE id = 1",
+				"SELECT * FROM users /* token */ WHERE id = 2",
+			},
+			want: "SELECT * FROM users WHERE id = ?",
+		},
+	}
+
+	for scenario, test := range tests {
+		test := test
+		t.Run(scenario, func(t *testing.T) {
+			for _, query := range test.queries {
+				require.Equal(t, test.want, sqlnorm.Fingerprint(query, test.dialect))
+			}
+		})
+	}
+}
+
+func TestAggregator_Top(t *testing.T) {
+	aggregator := slowlog.NewAggregator()
+	for _, entry := range []slowlog.Entry{
+		{Fingerprint: "SELECT a", Role: gormix.RoleRead, Duration: 100 * time.Millisecond, Caller: "a.go:1"},
+		{Fingerprint: "SELECT a", Role: gormix.RoleRead, Duration: 300 * time.Millisecond, Caller: "a.go:2"},
+		{Fingerprint: "SELECT b", Role: gormix.RoleRead, Duration: 250 * time.Millisecond, Caller: "b.go:1"},
+		{Fingerprint: "UPDATE c", Role: gormix.RoleWrite, Duration: time.Second, Caller: "c.go:1"},
+	} {
+		aggregator.Record(entry)
+	}
+
+	require.Equal(t, []slowlog.Stats{
+		{Fingerprint: "UPDATE c", Role: gormix.RoleWrite, Count: 1, Total: time.Second, Max: time.Second, Caller: "c.go:1"},
+		{Fingerprint: "SELECT a", Role: gormix.RoleRead, Count: 2, Total: 400 * time.Millisecond, Max: 300 * time.Millisecond, Caller: "a.go:2"},
+	}, aggregator.Top(2))
+	require.Equal(t, []slowlog.Stats{
+		{Fingerprint: "SELECT a", Role: gormix.RoleRead, Count: 2, Total: 400 * time.Millisecond, Max: 300 * time.Millisecond, Caller: "a.go:2"},
+		{Fingerprint: "SELECT b", Role: gormix.RoleRead, Count: 1, Total: 250 * time.Millisecond, Max: 250 * time.Millisecond, Caller: "b.go:1"},
+	}, aggregator.Top(5, gormix.RoleRead))
+
+	aggregator.Reset()
+	require.Empty(t, aggregator.Top(5))
+}
@@ -0,0 +1,94 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"github.com/XuanHieuHo/spread-db/constant"
+	"github.com/XuanHieuHo/spread-db/gormix"
+	"github.com/XuanHieuHo/spread-db/gormix/repository"
+	"github.com/XuanHieuHo/spread-db/gormix/spreaddbtest"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+type Book struct {
+	ID    int64  `gorm:"column:id;primaryKey" json:"id"`
+	Title string `gorm:"column:title;not null" json:"title"`
+}
+
+// recordingTB captures assertion failures instead of failing the test.
+type recordingTB struct {
+	testing.TB
+	failures []string
+}
+
+func (tb *recordingTB) Errorf(format string, args ...interface{}) {
+	tb.failures = append(tb.failures, fmt.Sprintf(format, args...))
+}
+
+func TestSpreadDBTest_ReadsSeeWrites(t *testing.T) {
+	db := spreaddbtest.New(t, &Book{})
+	books := repository.New[Book](db.DBProvider)
+	ctx := context.Background()
+
+	book, err := books.Create(ctx, Book{Title: "Dune"})
+	require.NoError(t, err)
+	got, err := books.Get(ctx, book.ID)
+	require.NoError(t, err)
+	require.Equal(t, "Dune", got.Title)
+	count, err := books.Count(ctx)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), count)
+
+	db.AssertQueryCount(gormix.RoleWrite, 1)
+	db.AssertQueryCount(gormix.RoleRead, 2)
+	db.AssertNoWritesOnRead()
+}
+
+func TestSpreadDBTest_Assertions(t *testing.T) {
+	tests := map[string]struct {
+		run          func(db *spreaddbtest.DB)
+		wantFailures int
+	}{
+		"success: reads only": {
+			run: func(db *spreaddbtest.DB) {
+				var books []Book
+				require.NoError(t, db.Read.Find(&books).Error())
+				db.AssertNoWritesOnRead()
+				db.AssertQueryCount(gormix.RoleRead, 1)
+			},
+		},
+		"failure: rejected write on read still reported": {
+			run: func(db *spreaddbtest.DB) {
+				err := db.Read.Raw(`DELETE FROM books`).Scan(&[]Book{}).Error()
+				require.Equal(t, constant.ErrWriteOperationOnReadDB, err)
+				db.AssertNoWritesOnRead()
+			},
+			wantFailures: 1,
+		},
+		"failure: unexpected query count": {
+			run: func(db *spreaddbtest.DB) {
+				db.AssertQueryCount(gormix.RoleWrite, 1)
+			},
+			wantFailures: 1,
+		},
+		"success: reset forgets seeded data statements": {
+			run: func(db *spreaddbtest.DB) {
+				require.NoError(t, db.Write.Create(&Book{Title: "Dune"}).Error())
+				db.Reset()
+				db.AssertQueryCount(gormix.RoleWrite, 0)
+			},
+		},
+	}
+
+	for scenario, test := range tests {
+		test := test
+		t.Run(scenario, func(t *testing.T) {
+			tb := &recordingTB{TB: t}
+			db := spreaddbtest.New(tb, &Book{})
+
+			test.run(db)
+			require.Len(t, tb.failures, test.wantFailures, tb.failures)
+		})
+	}
+}
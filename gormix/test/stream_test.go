@@ -0,0 +1,175 @@
+package test
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/XuanHieuHo/spread-db/gormix/stream"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"regexp"
+	"testing"
+)
+
+func TestStreamRows(t *testing.T) {
+	tests := map[string]struct {
+		setupMock func(mock sqlmock.Sqlmock)
+		breakAt   int
+		cancelAt  int
+		wantIDs   []int64
+		wantErr   error
+	}{
+		"success: all rows": {
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "user_dummies" WHERE id > $1`)).
+					WithArgs(0).
+					WillReturnRows(userRows(1, 2, 3)).
+					RowsWillBeClosed()
+			},
+			wantIDs: []int64{1, 2, 3},
+		},
+		"success: early break closes cursor": {
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "user_dummies" WHERE id > $1`)).
+					WithArgs(0).
+					WillReturnRows(userRows(1, 2, 3)).
+					RowsWillBeClosed()
+			},
+			breakAt: 2,
+			wantIDs: []int64{1, 2},
+		},
+		"failure: context cancelled": {
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "user_dummies" WHERE id > $1`)).
+					WithArgs(0).
+					WillReturnRows(userRows(1, 2, 3)).
+					RowsWillBeClosed()
+			},
+			cancelAt: 1,
+			wantIDs:  []int64{1},
+			wantErr:  context.Canceled,
+		},
+		"failure: query error": {
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "user_dummies" WHERE id > $1`)).
+					WithArgs(0).
+					WillReturnError(assert.AnError)
+			},
+			wantErr: assert.AnError,
+		},
+		"failure: error while reading rows": {
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "user_dummies" WHERE id > $1`)).
+					WithArgs(0).
+					WillReturnRows(userRows(1, 2).RowError(1, assert.AnError))
+			},
+			wantIDs: []int64{1},
+			wantErr: assert.AnError,
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			db, mock, cleanup := setupTestReadDB(t)
+			defer cleanup()
+			tc.setupMock(mock)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			var (
+				ids []int64
+				err error
+			)
+			for user, iterErr := range stream.Rows[UserDummy](ctx, db.Where("id > ?", 0)) {
+				if iterErr != nil {
+					err = iterErr
+					break
+				}
+				ids = append(ids, user.ID)
+				if len(ids) == tc.cancelAt {
+					cancel()
+				}
+				if len(ids) == tc.breakAt {
+					break
+				}
+			}
+
+			require.Equal(t, tc.wantIDs, ids)
+			require.ErrorIs(t, err, tc.wantErr)
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestStreamBatches(t *testing.T) {
+	tests := map[string]struct {
+		setupMock   func(mock sqlmock.Sqlmock)
+		breakAfter  int
+		wantBatches [][]int64
+		wantErr     error
+	}{
+		"success: all batches": {
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "user_dummies" ORDER BY "user_dummies"."id" LIMIT $1`)).
+					WithArgs(2).
+					WillReturnRows(userRows(1, 2))
+				mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "user_dummies" WHERE "user_dummies"."id" > $1 ORDER BY "user_dummies"."id" LIMIT $2`)).
+					WithArgs(int64(2), 2).
+					WillReturnRows(userRows(3, 4))
+				mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "user_dummies" WHERE "user_dummies"."id" > $1 ORDER BY "user_dummies"."id" LIMIT $2`)).
+					WithArgs(int64(4), 2).
+					WillReturnRows(userRows(5))
+			},
+			wantBatches: [][]int64{{1, 2}, {3, 4}, {5}},
+		},
+		"success: early break stops querying": {
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "user_dummies" ORDER BY "user_dummies"."id" LIMIT $1`)).
+					WithArgs(2).
+					WillReturnRows(userRows(1, 2))
+			},
+			breakAfter:  1,
+			wantBatches: [][]int64{{1, 2}},
+		},
+		"failure: query error": {
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "user_dummies" ORDER BY "user_dummies"."id" LIMIT $1`)).
+					WithArgs(2).
+					WillReturnRows(userRows(1, 2))
+				mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "user_dummies" WHERE "user_dummies"."id" > $1 ORDER BY "user_dummies"."id" LIMIT $2`)).
+					WithArgs(int64(2), 2).
+					WillReturnError(assert.AnError)
+			},
+			wantBatches: [][]int64{{1, 2}},
+			wantErr:     assert.AnError,
+		},
+	}
+
+	for name, tc := range tests {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			db, mock, cleanup := setupTestReadDB(t)
+			defer cleanup()
+			tc.setupMock(mock)
+
+			var (
+				batches [][]int64
+				err     error
+			)
+			for batch, iterErr := range stream.Batches[UserDummy](context.Background(), db, 2) {
+				if iterErr != nil {
+					err = iterErr
+					break
+				}
+				batches = append(batches, userIDs(batch))
+				if len(batches) == tc.breakAfter {
+					break
+				}
+			}
+
+			require.Equal(t, tc.wantBatches, batches)
+			require.ErrorIs(t, err, tc.wantErr)
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package test
+
+import (
+	"context"
+	"github.com/XuanHieuHo/spread-db/gormix"
+	"github.com/XuanHieuHo/spread-db/gormix/provider"
+	"github.com/XuanHieuHo/spread-db/gormix/sqlnorm"
+	"github.com/XuanHieuHo/spread-db/gormix/tracing"
+	"github.com/XuanHieuHo/spread-db/gormix/tracing/oteltrace"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"gorm.io/gorm"
+	"regexp"
+	"testing"
+)
+
+func spanAttributes(span sdktrace.ReadOnlySpan) map[string]attribute.Value {
+	attrs := make(map[string]attribute.Value)
+	for _, kv := range span.Attributes() {
+		attrs[string(kv.Key)] = kv.Value
+	}
+	return attrs
+}
+
+func TestDBProvider_Tracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tracerProvider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	otelTracer := tracerProvider.Tracer("spreaddb-test")
+
+	writeDB, writeMock := setupMockGormDB(t)
+	replicas, mocks := setupTestReplicas(t, 1)
+	dbProvider, err := provider.NewDBProviderWithReplicas(writeDB, replicas, provider.WithTracing(oteltrace.New(otelTracer)))
+	require.NoError(t, err)
+
+	mocks[0].ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "user_dummies" WHERE name = 'User 1'`)).WillReturnRows(createDummyUsers(1))
+	writeMock.ExpectExec(regexp.QuoteMeta(`UPDATE "cities" SET "name" = 'Hue' WHERE id = 42`)).WillReturnError(assert.AnError)
+
+	ctx, parent := otelTracer.Start(context.Background(), "handler")
+	var users []UserDummy
+	require.NoError(t, dbProvider.Read.WithContext(ctx).Where("name = 'User 1'").Find(&users).Error())
+	require.Equal(t, assert.AnError, dbProvider.Write.WithContext(ctx).Exec(`UPDATE "cities" SET "name" = 'Hue' WHERE id = 42`).Error())
+	parent.End()
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 3)
+	tests := map[string]struct {
+		span       sdktrace.ReadOnlySpan
+		wantName   string
+		wantAttrs  map[string]string
+		wantStatus codes.Code
+	}{
+		"read span": {
+			span:     spans[0],
+			wantName: "spreaddb.query",
+			wantAttrs: map[string]string{
+				tracing.AttrDBSystem:    "postgres",
+				tracing.AttrDBStatement: `SELECT * FROM "user_dummies" WHERE name = ?`,
+				tracing.AttrDBOperation: "SELECT",
+				tracing.AttrDBTable:     "user_dummies",
+				tracing.AttrRole:        gormix.RoleRead,
+				tracing.AttrReplica:     "a",
+			},
+			wantStatus: codes.Unset,
+		},
+		"write span": {
+			span:     spans[1],
+			wantName: "spreaddb.raw",
+			wantAttrs: map[string]string{
+				tracing.AttrDBSystem:    "postgres",
+				tracing.AttrDBStatement: `UPDATE "cities" SET "name" = ? WHERE id = ?`,
+				tracing.AttrDBOperation: "UPDATE",
+				tracing.AttrRole:        gormix.RoleWrite,
+				tracing.AttrReplica:     "primary",
+			},
+			wantStatus: codes.Error,
+		},
+	}
+
+	for scenario, test := range tests {
+		test := test
+		t.Run(scenario, func(t *testing.T) {
+			require.Equal(t, test.wantName, test.span.Name())
+			require.Equal(t, parent.SpanContext().SpanID(), test.span.Parent().SpanID())
+			attrs := spanAttributes(test.span)
+			for key, want := range test.wantAttrs {
+				require.Equal(t, want, attrs[key].AsString(), key)
+			}
+			require.Equal(t, test.wantStatus, test.span.Status().Code)
+		})
+	}
+	require.NoError(t, writeMock.ExpectationsWereMet())
+	require.NoError(t, mocks[0].ExpectationsWereMet())
+}
+
+func TestDBProvider_TracingNestsDriverSpans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tracerProvider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	otelTracer := tracerProvider.Tracer("spreaddb-test")
+
+	writeDB, _ := setupMockGormDB(t)
+	replicas, mocks := setupTestReplicas(t, 1)
+	dbProvider, err := provider.NewDBProviderWithReplicas(writeDB, replicas, provider.WithTracing(oteltrace.New(otelTracer)))
+	require.NoError(t, err)
+	// stands in for an instrumented driver, which starts its spans from the query's context
+	require.NoError(t, replicas[0].DB.Callback().Query().Before("gorm:query").Register("test:driver_span", func(db *gorm.DB) {
+		_, span := otelTracer.Start(db.Statement.Context, "driver.query")
+		span.End()
+	}))
+	mocks[0].ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "user_dummies"`)).WillReturnRows(createDummyUsers(1))
+
+	ctx, parent := otelTracer.Start(context.Background(), "handler")
+	require.NoError(t, dbProvider.Read.WithContext(ctx).Find(&[]UserDummy{}).Error())
+	parent.End()
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 3)
+	require.Equal(t, "driver.query", spans[0].Name())
+	require.Equal(t, "spreaddb.query", spans[1].Name())
+	require.Equal(t, spans[1].SpanContext().SpanID(), spans[0].Parent().SpanID())
+	require.Equal(t, parent.SpanContext().SpanID(), spans[1].Parent().SpanID())
+	require.NoError(t, mocks[0].ExpectationsWereMet())
+}
+
+func TestSqlnorm_Sanitize(t *testing.T) {
+	tests := map[string]struct {
+		query   string
+		dialect string
+		want    string
+	}{
+		"placeholders kept":              {query: `SELECT * FROM "users" WHERE id = $1 AND name = ?`, dialect: "postgres", want: `SELECT * FROM "users" WHERE id = $1 AND name = ?`},
+		"string literal":                 {query: `SELECT * FROM users WHERE email = 'a''b@example.com'`, dialect: "postgres", want: `SELECT * FROM users WHERE email = ?`},
+		"escape string":                  {query: `SELECT E'it\'s'`, dialect: "postgres", want: `SELECT ?`},
+		"postgres: backslash is literal": {query: `SELECT * FROM t WHERE path = 'C:\' AND token = 'secret'`, dialect: "postgres", want: `SELECT * FROM t WHERE path = ? AND token = ?`},
+		"sqlite: backslash is literal":   {query: `SELECT * FROM t WHERE path = 'C:\' AND token = 'secret'`, dialect: "sqlite", want: `SELECT * FROM t WHERE path = ? AND token = ?`},
+		"mysql: backslash escape":        {query: `SELECT * FROM t WHERE name = 'it\'s' AND token = 'secret'`, dialect: "mysql", want: `SELECT * FROM t WHERE name = ? AND token = ?`},
+		"mysql: double quoted string":    {query: "SELECT * FROM `t` WHERE token = \"secret\"", dialect: "mysql", want: "SELECT * FROM `t` WHERE token = ?"},
+		"numbers":                        {query: `SELECT * FROM t1 WHERE price > 10.5 LIMIT 20`, dialect: "postgres", want: `SELECT * FROM t1 WHERE price > ? LIMIT ?`},
+		"quoted identifier kept":         {query: `SELECT "col 1" FROM "t'x"`, dialect: "postgres", want: `SELECT "col 1" FROM "t'x"`},
+		"comments dropped":               {query: "SELECT 1 -- secret\nFROM t /* token */", dialect: "postgres", want: "SELECT ? \nFROM t "},
+		"dollar quoted":                  {query: `SELECT $tag$secret$tag$, $$x$$`, dialect: "postgres", want: `SELECT ?, ?`},
+	}
+
+	for scenario, test := range tests {
+		test := test
+		t.Run(scenario, func(t *testing.T) {
+			require.Equal(t, test.want, sqlnorm.Sanitize(test.query, test.dialect))
+		})
+	}
+}
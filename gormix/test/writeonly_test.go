@@ -2,11 +2,15 @@ package test
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/XuanHieuHo/spread-db/constant"
 	"github.com/XuanHieuHo/spread-db/gormix"
 	"github.com/XuanHieuHo/spread-db/gormix/provider"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"gorm.io/driver/mysql"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"regexp"
@@ -39,6 +43,22 @@ func setupTestWriteDB(t *testing.T) (gormix.WriteOnlyDB, sqlmock.Sqlmock, func()
 	return dbProvider.Write, mock, cleanup
 }
 
+func setupTestMySQLWriteDB(t *testing.T) (gormix.WriteOnlyDB, sqlmock.Sqlmock) {
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		sqlDB.Close()
+	})
+
+	db, err := gorm.Open(mysql.New(mysql.Config{
+		Conn:                      sqlDB,
+		SkipInitializeWithVersion: true,
+	}), &gorm.Config{})
+	require.NoError(t, err)
+
+	return provider.NewDBProvider(&gorm.DB{}, db).Write, mock
+}
+
 func TestWriteDB_Create(t *testing.T) {
 	tests := map[string]struct {
 		setupMock  func(mock sqlmock.Sqlmock)
@@ -867,3 +887,472 @@ func TestWriteDB_Transaction(t *testing.T) {
 		})
 	}
 }
+
+func TestWriteDB_NestedTransaction(t *testing.T) {
+	insertCity := func(mock sqlmock.Sqlmock, name string, err error) {
+		exec := mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "cities" ("name") VALUES ($1)`)).WithArgs(name)
+		if err != nil {
+			exec.WillReturnError(err)
+		} else {
+			exec.WillReturnResult(sqlmock.NewResult(1, 1))
+		}
+	}
+	tests := map[string]struct {
+		setupMock func(mock sqlmock.Sqlmock)
+		txFunc    func(tx gormix.WriteOnlyDB) error
+		wantErr   error
+		wantPanic bool
+	}{
+		"success: inner savepoint released": {
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				insertCity(mock, "Hanoi", nil)
+				mock.ExpectExec(`SAVEPOINT sp\d+`).WillReturnResult(sqlmock.NewResult(0, 0))
+				insertCity(mock, "Hue", nil)
+				mock.ExpectExec(`RELEASE SAVEPOINT sp\d+`).WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectCommit()
+			},
+			txFunc: func(tx gormix.WriteOnlyDB) error {
+				if err := tx.Exec(`INSERT INTO "cities" ("name") VALUES (?)`, "Hanoi").Error(); err != nil {
+					return err
+				}
+				return tx.Transaction(func(inner gormix.WriteOnlyDB) error {
+					return inner.Exec(`INSERT INTO "cities" ("name") VALUES (?)`, "Hue").Error()
+				})
+			},
+		},
+		"success: inner failure only rolls back its savepoint": {
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				insertCity(mock, "Hanoi", nil)
+				mock.ExpectExec(`SAVEPOINT sp\d+`).WillReturnResult(sqlmock.NewResult(0, 0))
+				insertCity(mock, "Hue", assert.AnError)
+				mock.ExpectExec(`ROLLBACK TO SAVEPOINT sp\d+`).WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectCommit()
+			},
+			txFunc: func(tx gormix.WriteOnlyDB) error {
+				if err := tx.Exec(`INSERT INTO "cities" ("name") VALUES (?)`, "Hanoi").Error(); err != nil {
+					return err
+				}
+				err := tx.Transaction(func(inner gormix.WriteOnlyDB) error {
+					return inner.Exec(`INSERT INTO "cities" ("name") VALUES (?)`, "Hue").Error()
+				})
+				if err != assert.AnError {
+					return err
+				}
+				return nil
+			},
+		},
+		"failure: inner error propagated rolls back everything": {
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(`SAVEPOINT sp\d+`).WillReturnResult(sqlmock.NewResult(0, 0))
+				insertCity(mock, "Hue", assert.AnError)
+				mock.ExpectExec(`ROLLBACK TO SAVEPOINT sp\d+`).WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectRollback()
+			},
+			txFunc: func(tx gormix.WriteOnlyDB) error {
+				return tx.Transaction(func(inner gormix.WriteOnlyDB) error {
+					return inner.Exec(`INSERT INTO "cities" ("name") VALUES (?)`, "Hue").Error()
+				})
+			},
+			wantErr: assert.AnError,
+		},
+		"failure: savepoint rollback error joined": {
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(`SAVEPOINT sp\d+`).WillReturnResult(sqlmock.NewResult(0, 0))
+				insertCity(mock, "Hue", assert.AnError)
+				mock.ExpectExec(`ROLLBACK TO SAVEPOINT sp\d+`).WillReturnError(sql.ErrConnDone)
+				mock.ExpectRollback()
+			},
+			txFunc: func(tx gormix.WriteOnlyDB) error {
+				return tx.Transaction(func(inner gormix.WriteOnlyDB) error {
+					return inner.Exec(`INSERT INTO "cities" ("name") VALUES (?)`, "Hue").Error()
+				})
+			},
+			wantErr: errors.Join(assert.AnError, sql.ErrConnDone),
+		},
+		"failure: inner panic": {
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(`SAVEPOINT sp\d+`).WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec(`ROLLBACK TO SAVEPOINT sp\d+`).WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectRollback()
+			},
+			txFunc: func(tx gormix.WriteOnlyDB) error {
+				return tx.Transaction(func(inner gormix.WriteOnlyDB) error {
+					panic("boom")
+				})
+			},
+			wantPanic: true,
+		},
+	}
+
+	for scenario, test := range tests {
+		test := test
+		t.Run(scenario, func(t *testing.T) {
+			db, mock, cleanup := setupTestWriteDB(t)
+			defer cleanup()
+			test.setupMock(mock)
+
+			if test.wantPanic {
+				require.Panics(t, func() {
+					_ = db.Transaction(test.txFunc)
+				})
+			} else {
+				require.Equal(t, test.wantErr, db.Transaction(test.txFunc))
+			}
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestWriteDB_SavePoint(t *testing.T) {
+	db, mock, cleanup := setupTestWriteDB(t)
+	defer cleanup()
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`SAVEPOINT before_city`)).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "cities" ("name") VALUES ($1)`)).
+		WithArgs("Hanoi").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`ROLLBACK TO SAVEPOINT before_city`)).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(`SAVEPOINT after_city`)).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(`RELEASE SAVEPOINT after_city`)).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	tx := db.Begin()
+	require.NoError(t, tx.SavePoint("before_city").Error())
+	require.NoError(t, tx.Exec(`INSERT INTO "cities" ("name") VALUES (?)`, "Hanoi").Error())
+	require.NoError(t, tx.RollbackTo("before_city").Error())
+	require.NoError(t, tx.SavePoint("after_city").Error())
+	require.NoError(t, tx.ReleaseSavePoint("after_city").Error())
+	require.NoError(t, tx.Commit())
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+// sqlServerDialector stands in for SQL Server, which has no RELEASE SAVEPOINT.
+type sqlServerDialector struct {
+	*postgres.Dialector
+}
+
+func (sqlServerDialector) Name() string {
+	return "sqlserver"
+}
+
+func TestWriteDB_NestedTransaction_SQLServer(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		sqlDB.Close()
+	})
+	dialector := postgres.New(postgres.Config{Conn: sqlDB, DriverName: "postgres"}).(*postgres.Dialector)
+	db, err := gorm.Open(sqlServerDialector{dialector}, &gorm.Config{})
+	require.NoError(t, err)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`SAVEPOINT sp\d+`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "cities" ("name") VALUES ($1)`)).
+		WithArgs("Hue").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	err = provider.NewDBProvider(&gorm.DB{}, db).Write.Transaction(func(tx gormix.WriteOnlyDB) error {
+		return tx.Transaction(func(inner gormix.WriteOnlyDB) error {
+			return inner.Exec(`INSERT INTO "cities" ("name") VALUES (?)`, "Hue").Error()
+		})
+	})
+
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestWriteDB_Upsert(t *testing.T) {
+	tests := map[string]struct {
+		setupMock     func(mock sqlmock.Sqlmock)
+		updateColumns []string
+		wantErr       error
+		wantResult    Animal
+	}{
+		"success: update selected columns": {
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "animals" ("name","id") VALUES ($1,$2) ON CONFLICT ("id") DO UPDATE SET "name"="excluded"."name" RETURNING *`)).
+					WithArgs("Cat", 1).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "name", "updated_at"}).AddRow(1, "Cat", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)))
+				mock.ExpectCommit()
+			},
+			updateColumns: []string{"name"},
+			wantResult:    Animal{ID: 1, Name: "Cat", UpdatedAt: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+		},
+		"success: update all columns": {
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "animals" ("name","id") VALUES ($1,$2) ON CONFLICT ("id") DO UPDATE SET "name"="excluded"."name" RETURNING *`)).
+					WithArgs("Cat", 1).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "name", "updated_at"}).AddRow(1, "Cat", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)))
+				mock.ExpectCommit()
+			},
+			wantResult: Animal{ID: 1, Name: "Cat", UpdatedAt: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+		},
+		"failure: query error": {
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "animals"`)).
+					WillReturnError(assert.AnError)
+				mock.ExpectRollback()
+			},
+			updateColumns: []string{"name"},
+			wantErr:       assert.AnError,
+			wantResult:    Animal{ID: 1, Name: "Cat"},
+		},
+	}
+
+	for scenario, test := range tests {
+		test := test
+		t.Run(scenario, func(t *testing.T) {
+			db, mock, cleanup := setupTestWriteDB(t)
+			defer cleanup()
+			test.setupMock(mock)
+
+			animal := Animal{ID: 1, Name: "Cat"}
+			err := db.Upsert(&animal, []string{"id"}, test.updateColumns...).Error()
+
+			require.Equal(t, test.wantErr, err)
+			require.Equal(t, test.wantResult, animal)
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestWriteDB_InsertIgnore(t *testing.T) {
+	tests := map[string]struct {
+		rows             *sqlmock.Rows
+		wantRowsAffected int64
+		wantResult       Animal
+	}{
+		"inserted": {
+			rows:             sqlmock.NewRows([]string{"id", "name", "updated_at"}).AddRow(1, "Cat", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)),
+			wantRowsAffected: 1,
+			wantResult:       Animal{ID: 1, Name: "Cat", UpdatedAt: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+		},
+		"conflict ignored": {
+			rows:       sqlmock.NewRows([]string{"id", "name", "updated_at"}),
+			wantResult: Animal{ID: 1, Name: "Cat"},
+		},
+	}
+
+	for scenario, test := range tests {
+		test := test
+		t.Run(scenario, func(t *testing.T) {
+			db, mock, cleanup := setupTestWriteDB(t)
+			defer cleanup()
+			mock.ExpectBegin()
+			mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "animals" ("name","id") VALUES ($1,$2) ON CONFLICT DO NOTHING RETURNING *`)).
+				WithArgs("Cat", 1).
+				WillReturnRows(test.rows)
+			mock.ExpectCommit()
+
+			animal := Animal{ID: 1, Name: "Cat"}
+			result := db.InsertIgnore(&animal)
+
+			require.NoError(t, result.Error())
+			require.Equal(t, test.wantRowsAffected, result.Statement().RowsAffected)
+			require.Equal(t, test.wantResult, animal)
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestWriteDB_MySQLUpsert(t *testing.T) {
+	tests := map[string]struct {
+		updateColumns []string
+		wantSQL       string
+	}{
+		"update selected columns": {
+			updateColumns: []string{"name"},
+			wantSQL:       "INSERT INTO `animals` (`name`,`id`) VALUES (?,?) ON DUPLICATE KEY UPDATE `name`=VALUES(`name`)",
+		},
+		"update all columns": {
+			wantSQL: "INSERT INTO `animals` (`name`,`id`) VALUES (?,?) ON DUPLICATE KEY UPDATE `name`=VALUES(`name`)",
+		},
+	}
+
+	for scenario, test := range tests {
+		test := test
+		t.Run(scenario, func(t *testing.T) {
+			db, mock := setupTestMySQLWriteDB(t)
+			mock.ExpectBegin()
+			mock.ExpectExec(regexp.QuoteMeta(test.wantSQL)).
+				WithArgs("Cat", 1).
+				WillReturnResult(sqlmock.NewResult(1, 1))
+			mock.ExpectCommit()
+
+			animal := Animal{ID: 1, Name: "Cat"}
+			require.NoError(t, db.Upsert(&animal, []string{"id"}, test.updateColumns...).Error())
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestWriteDB_MySQLInsertIgnore(t *testing.T) {
+	tests := map[string]struct {
+		rowsAffected     int64
+		wantRowsAffected int64
+	}{
+		"inserted":         {rowsAffected: 1, wantRowsAffected: 1},
+		"conflict ignored": {rowsAffected: 0, wantRowsAffected: 0},
+	}
+
+	for scenario, test := range tests {
+		test := test
+		t.Run(scenario, func(t *testing.T) {
+			db, mock := setupTestMySQLWriteDB(t)
+			mock.ExpectBegin()
+			mock.ExpectExec(regexp.QuoteMeta("INSERT IGNORE INTO `animals` (`name`,`id`) VALUES (?,?)")).
+				WithArgs("Cat", 1).
+				WillReturnResult(sqlmock.NewResult(1, test.rowsAffected))
+			mock.ExpectCommit()
+
+			animal := Animal{ID: 1, Name: "Cat"}
+			result := db.InsertIgnore(&animal)
+
+			require.NoError(t, result.Error())
+			require.Equal(t, test.wantRowsAffected, result.Statement().RowsAffected)
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestWriteDB_FirstOrCreate(t *testing.T) {
+	tests := map[string]struct {
+		setupMock  func(mock sqlmock.Sqlmock)
+		build      func(db gormix.WriteOnlyDB) gormix.WriteOnlyDB
+		wantResult UserDummy
+	}{
+		"found: assign updates the record": {
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "user_dummies" WHERE "user_dummies"."name" = $1 ORDER BY "user_dummies"."id" LIMIT $2`)).
+					WithArgs("User 1", 1).
+					WillReturnRows(createDummyUsers(1))
+				mock.ExpectBegin()
+				mock.ExpectExec(regexp.QuoteMeta(`UPDATE "user_dummies" SET "email"=$1 WHERE "id" = $2`)).
+					WithArgs("new@example.com", 1).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+			build: func(db gormix.WriteOnlyDB) gormix.WriteOnlyDB {
+				return db.Assign(UserDummy{Email: "new@example.com"})
+			},
+			wantResult: UserDummy{ID: 1, Name: "User 1", Email: "new@example.com"},
+		},
+		"not found: attrs are used on create": {
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "user_dummies" WHERE "user_dummies"."name" = $1 ORDER BY "user_dummies"."id" LIMIT $2`)).
+					WithArgs("User 1", 1).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "name", "email"}))
+				mock.ExpectBegin()
+				mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "user_dummies" ("name","email") VALUES ($1,$2) RETURNING "id"`)).
+					WithArgs("User 1", "new@example.com").
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+				mock.ExpectCommit()
+			},
+			build: func(db gormix.WriteOnlyDB) gormix.WriteOnlyDB {
+				return db.Attrs(UserDummy{Email: "new@example.com"})
+			},
+			wantResult: UserDummy{ID: 7, Name: "User 1", Email: "new@example.com"},
+		},
+	}
+
+	for scenario, test := range tests {
+		test := test
+		t.Run(scenario, func(t *testing.T) {
+			db, mock, cleanup := setupTestWriteDB(t)
+			defer cleanup()
+			test.setupMock(mock)
+
+			var user UserDummy
+			err := test.build(db).FirstOrCreate(&user, UserDummy{Name: "User 1"}).Error()
+
+			require.NoError(t, err)
+			require.Equal(t, test.wantResult, user)
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestWriteDB_Lock(t *testing.T) {
+	tests := map[string]struct {
+		lock    func(db gormix.WriteOnlyDB) gormix.WriteOnlyDB
+		wantSQL string
+		wantErr error
+	}{
+		"for update": {
+			lock: func(db gormix.WriteOnlyDB) gormix.WriteOnlyDB {
+				return db.LockForUpdate()
+			},
+			wantSQL: `SELECT * FROM "user_dummies" WHERE name = $1 FOR UPDATE`,
+		},
+		"for update skip locked": {
+			lock: func(db gormix.WriteOnlyDB) gormix.WriteOnlyDB {
+				return db.LockForUpdate(gormix.LockOptions{SkipLocked: true})
+			},
+			wantSQL: `SELECT * FROM "user_dummies" WHERE name = $1 FOR UPDATE SKIP LOCKED`,
+		},
+		"for share of table nowait": {
+			lock: func(db gormix.WriteOnlyDB) gormix.WriteOnlyDB {
+				return db.LockForShare(gormix.LockOptions{Of: "user_dummies", NoWait: true})
+			},
+			wantSQL: `SELECT * FROM "user_dummies" WHERE name = $1 FOR SHARE OF "user_dummies" NOWAIT`,
+		},
+		"failure: skip locked and nowait": {
+			lock: func(db gormix.WriteOnlyDB) gormix.WriteOnlyDB {
+				return db.LockForUpdate(gormix.LockOptions{SkipLocked: true, NoWait: true})
+			},
+			wantErr: constant.ErrInvalidLockOptions,
+		},
+		"failure: skip locked and nowait in separate options": {
+			lock: func(db gormix.WriteOnlyDB) gormix.WriteOnlyDB {
+				return db.LockForUpdate(gormix.LockOptions{SkipLocked: true}, gormix.LockOptions{NoWait: true})
+			},
+			wantErr: constant.ErrInvalidLockOptions,
+		},
+	}
+
+	for scenario, test := range tests {
+		test := test
+		t.Run(scenario, func(t *testing.T) {
+			db, mock, cleanup := setupTestWriteDB(t)
+			defer cleanup()
+			mock.ExpectBegin()
+			if test.wantErr == nil {
+				mock.ExpectQuery(regexp.QuoteMeta(test.wantSQL)).
+					WithArgs("User 1").
+					WillReturnRows(createDummyUsers(1))
+				mock.ExpectCommit()
+			} else {
+				mock.ExpectRollback()
+			}
+
+			err := db.Transaction(func(tx gormix.WriteOnlyDB) error {
+				var users []UserDummy
+				return test.lock(tx.Where("name = ?", "User 1")).Find(&users).Error()
+			})
+
+			require.Equal(t, test.wantErr, err)
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestWriteDB_Lock_OutsideTransaction(t *testing.T) {
+	db, mock, cleanup := setupTestWriteDB(t)
+	defer cleanup()
+
+	var users []UserDummy
+	err := db.Where("name = ?", "User 1").LockForUpdate().Find(&users).Error()
+
+	require.Equal(t, constant.ErrLockOutsideTransaction, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,65 @@
+package oteltrace
+
+import (
+	"context"
+	"fmt"
+	"github.com/XuanHieuHo/spread-db/gormix/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type tracer struct {
+	tracer trace.Tracer
+}
+
+// New adapts an OpenTelemetry tracer to tracing.Tracer. Spans are client spans.
+func New(t trace.Tracer) tracing.Tracer {
+	return tracer{tracer: t}
+}
+
+func (t tracer) Start(ctx context.Context, name string, attrs ...tracing.Attribute) (context.Context, tracing.Span) {
+	ctx, s := t.tracer.Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(convert(attrs)...),
+	)
+	return ctx, span{span: s}
+}
+
+type span struct {
+	span trace.Span
+}
+
+func (s span) SetAttributes(attrs ...tracing.Attribute) {
+	s.span.SetAttributes(convert(attrs)...)
+}
+
+func (s span) RecordError(err error) {
+	s.span.RecordError(err)
+	s.span.SetStatus(codes.Error, err.Error())
+}
+
+func (s span) End() {
+	s.span.End()
+}
+
+func convert(attrs []tracing.Attribute) []attribute.KeyValue {
+	kvs := make([]attribute.KeyValue, 0, len(attrs))
+	for _, attr := range attrs {
+		switch v := attr.Value.(type) {
+		case string:
+			kvs = append(kvs, attribute.String(attr.Key, v))
+		case int:
+			kvs = append(kvs, attribute.Int(attr.Key, v))
+		case int64:
+			kvs = append(kvs, attribute.Int64(attr.Key, v))
+		case float64:
+			kvs = append(kvs, attribute.Float64(attr.Key, v))
+		case bool:
+			kvs = append(kvs, attribute.Bool(attr.Key, v))
+		default:
+			kvs = append(kvs, attribute.String(attr.Key, fmt.Sprint(v)))
+		}
+	}
+	return kvs
+}
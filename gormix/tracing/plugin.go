@@ -0,0 +1,75 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"github.com/XuanHieuHo/spread-db/gormix"
+	"github.com/XuanHieuHo/spread-db/gormix/sqlnorm"
+	"gorm.io/gorm"
+)
+
+const (
+	PluginName = "spreaddb:tracing"
+	spanKey    = "spreaddb:tracing_span"
+)
+
+type spanState struct {
+	span   Span
+	parent context.Context
+}
+
+// Plugin is a gorm plugin that wraps every statement run on a pool in a span,
+// child of the span found in the statement's context. While the statement runs,
+// its context carries the new span, so driver-level spans nest under it.
+type Plugin struct {
+	tracer  Tracer
+	role    string
+	replica string
+}
+
+func NewPlugin(tracer Tracer, role string, replica string) *Plugin {
+	return &Plugin{tracer: tracer, role: role, replica: replica}
+}
+
+func (p *Plugin) Name() string {
+	return PluginName
+}
+
+func (p *Plugin) Initialize(db *gorm.DB) error {
+	return gormix.RegisterAround(db, "spreaddb:tracing", p.start, p.end)
+}
+
+func (p *Plugin) start(operation string, db *gorm.DB) {
+	if db.DryRun {
+		return
+	}
+	ctx, span := p.tracer.Start(db.Statement.Context, "spreaddb."+operation,
+		Attribute{Key: AttrDBSystem, Value: db.Dialector.Name()},
+		Attribute{Key: AttrRole, Value: gormix.RoleOf(db, p.role)},
+		Attribute{Key: AttrReplica, Value: p.replica},
+	)
+	db.InstanceSet(spanKey, spanState{span: span, parent: db.Statement.Context})
+	db.Statement.Context = ctx
+}
+
+func (p *Plugin) end(_ string, db *gorm.DB) {
+	value, ok := db.InstanceGet(spanKey)
+	if !ok {
+		return
+	}
+	state := value.(spanState)
+	span := state.span
+	defer span.End()
+	db.Statement.Context = state.parent
+
+	query := db.Statement.SQL.String()
+	span.SetAttributes(
+		Attribute{Key: AttrDBStatement, Value: sqlnorm.Sanitize(query, db.Dialector.Name())},
+		Attribute{Key: AttrDBOperation, Value: sqlnorm.Operation(query, db.Dialector.Name())},
+		Attribute{Key: AttrDBTable, Value: db.Statement.Table},
+		Attribute{Key: AttrRowsAffected, Value: db.RowsAffected},
+	)
+	if db.Error != nil && !errors.Is(db.Error, gorm.ErrRecordNotFound) {
+		span.RecordError(db.Error)
+	}
+}
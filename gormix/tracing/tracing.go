@@ -0,0 +1,32 @@
+package tracing
+
+import (
+	"context"
+)
+
+const (
+	AttrDBSystem     = "db.system"
+	AttrDBStatement  = "db.statement"
+	AttrDBOperation  = "db.operation"
+	AttrDBTable      = "db.sql.table"
+	AttrRowsAffected = "db.rows_affected"
+	AttrRole         = "spreaddb.role"
+	AttrReplica      = "spreaddb.replica"
+)
+
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+// Tracer starts spans. Adapt a tracing library to it, e.g. with oteltrace.New.
+type Tracer interface {
+	Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, Span)
+}
+
+type Span interface {
+	SetAttributes(attrs ...Attribute)
+	// RecordError records err and marks the span as failed.
+	RecordError(err error)
+	End()
+}
@@ -3,17 +3,38 @@ package writeonly
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/XuanHieuHo/spread-db/constant"
 	"github.com/XuanHieuHo/spread-db/gormix"
+	"github.com/XuanHieuHo/spread-db/gormix/consistency"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
+	"gorm.io/gorm/utils"
+	"sync/atomic"
 )
 
 type writeDB struct {
 	db *gorm.DB
 }
 
+var savePointSeq atomic.Uint64
+
+// WithContext joins the transaction carried by ctx unless ctx asks for
+// PropagationRequiresNew, in which case the chain runs outside of any transaction.
+// Only the connection is swapped; the clauses built so far are kept.
 func (w writeDB) WithContext(ctx context.Context) gormix.WriteOnlyDB {
-	return &writeDB{w.db.WithContext(ctx)}
+	db := w.db.WithContext(ctx)
+	if gormix.PropagationFromContext(ctx) == gormix.PropagationRequiresNew {
+		db.Statement.ConnPool = db.ConnPool
+		return &writeDB{db}
+	}
+	if tx, ok := gormix.TxFromContext(ctx); ok {
+		if tx, ok := tx.(*writeDB); ok {
+			db.Statement.ConnPool = tx.db.Statement.ConnPool
+		}
+	}
+	return &writeDB{db}
 }
 
 func (w writeDB) Table(name string) gormix.WriteOnlyDB {
@@ -183,10 +204,172 @@ func (w writeDB) Exec(sql string, values ...interface{}) gormix.WriteOnlyDB {
 	return &writeDB{w.db.Exec(sql, values...)}
 }
 
+// Upsert inserts value, or updates updateColumns (every column when none are
+// given) of the row that conflicts on conflictColumns. MySQL ignores
+// conflictColumns and uses ON DUPLICATE KEY UPDATE. Generated fields are read
+// back with RETURNING on dialects that support it.
+func (w writeDB) Upsert(value interface{}, conflictColumns []string, updateColumns ...string) gormix.WriteOnlyDB {
+	onConflict := clause.OnConflict{UpdateAll: len(updateColumns) == 0}
+	for _, column := range conflictColumns {
+		onConflict.Columns = append(onConflict.Columns, clause.Column{Name: column})
+	}
+	if len(updateColumns) > 0 {
+		onConflict.DoUpdates = clause.AssignmentColumns(updateColumns)
+	}
+	return &writeDB{w.returning().Clauses(onConflict).Create(value)}
+}
+
+// InsertIgnore inserts value unless it conflicts with an existing row, in which
+// case RowsAffected is 0 and no error is returned.
+func (w writeDB) InsertIgnore(value interface{}) gormix.WriteOnlyDB {
+	var ignore clause.Expression = clause.OnConflict{DoNothing: true}
+	if w.db.Dialector.Name() == "mysql" {
+		ignore = clause.Insert{Modifier: "IGNORE"}
+	}
+	return &writeDB{w.returning().Clauses(ignore).Create(value)}
+}
+
+// returning asks for every column back so defaults, triggers and the columns
+// changed by an upsert are populated on the value.
+func (w writeDB) returning() *gorm.DB {
+	if !utils.Contains(w.db.Callback().Create().Clauses, "RETURNING") {
+		return w.db
+	}
+	if _, ok := w.db.Statement.Clauses["RETURNING"]; ok {
+		return w.db
+	}
+	return w.db.Clauses(clause.Returning{})
+}
+
+func (w writeDB) FirstOrCreate(dest interface{}, conds ...interface{}) gormix.WriteOnlyDB {
+	return &writeDB{w.db.FirstOrCreate(dest, conds...)}
+}
+
+func (w writeDB) FirstOrInit(dest interface{}, conds ...interface{}) gormix.WriteOnlyDB {
+	return &writeDB{w.db.FirstOrInit(dest, conds...)}
+}
+
+func (w writeDB) Attrs(attrs ...interface{}) gormix.WriteOnlyDB {
+	return &writeDB{w.db.Attrs(attrs...)}
+}
+
+func (w writeDB) Assign(attrs ...interface{}) gormix.WriteOnlyDB {
+	return &writeDB{w.db.Assign(attrs...)}
+}
+
+// LockForUpdate adds FOR UPDATE to the chain's SELECT. It fails with
+// constant.ErrLockOutsideTransaction unless the chain runs in a transaction.
+func (w writeDB) LockForUpdate(opts ...gormix.LockOptions) gormix.WriteOnlyDB {
+	return w.lock(clause.LockingStrengthUpdate, opts)
+}
+
+// LockForShare adds FOR SHARE to the chain's SELECT. It fails with
+// constant.ErrLockOutsideTransaction unless the chain runs in a transaction.
+func (w writeDB) LockForShare(opts ...gormix.LockOptions) gormix.WriteOnlyDB {
+	return w.lock(clause.LockingStrengthShare, opts)
+}
+
+func (w writeDB) lock(strength string, opts []gormix.LockOptions) gormix.WriteOnlyDB {
+	if _, inTx := w.db.Statement.ConnPool.(gorm.TxCommitter); !inTx {
+		tx := w.db.Session(&gorm.Session{})
+		tx.AddError(constant.ErrLockOutsideTransaction)
+		return &writeDB{tx}
+	}
+	locking := clause.Locking{Strength: strength}
+	var skipLocked, noWait bool
+	for _, opt := range opts {
+		if opt.Of != "" {
+			locking.Table = clause.Table{Name: opt.Of}
+		}
+		skipLocked = skipLocked || opt.SkipLocked
+		noWait = noWait || opt.NoWait
+	}
+	switch {
+	case skipLocked && noWait:
+		tx := w.db.Session(&gorm.Session{})
+		tx.AddError(constant.ErrInvalidLockOptions)
+		return &writeDB{tx}
+	case skipLocked:
+		locking.Options = clause.LockingOptionsSkipLocked
+	case noWait:
+		locking.Options = clause.LockingOptionsNoWait
+	}
+	return &writeDB{w.db.Clauses(locking)}
+}
+
 func (w writeDB) Transaction(fc func(tx gormix.WriteOnlyDB) error, opts ...*sql.TxOptions) error {
-	return w.db.Transaction(func(tx *gorm.DB) error {
-		return fc(&writeDB{db: tx})
+	if _, nested := w.db.Statement.ConnPool.(gorm.TxCommitter); nested {
+		return w.nestedTransaction(fc)
+	}
+	err := w.db.Transaction(func(tx *gorm.DB) error {
+		joinable := &writeDB{db: tx.Session(&gorm.Session{NewDB: true, Context: tx.Statement.Context})}
+		return fc(&writeDB{db: tx.WithContext(gormix.WithTx(tx.Statement.Context, joinable))})
 	}, opts...)
+	if err == nil {
+		consistency.Committed(w.db)
+	}
+	return err
+}
+
+// nestedTransaction runs fc inside a savepoint of the current transaction, rolling
+// back to it when fc fails or panics and releasing it otherwise.
+func (w writeDB) nestedTransaction(fc func(tx gormix.WriteOnlyDB) error) (err error) {
+	tx := &writeDB{db: w.db.Session(&gorm.Session{})}
+	if w.db.DisableNestedTransaction {
+		return fc(tx)
+	}
+
+	name := fmt.Sprintf("sp%d", savePointSeq.Add(1))
+	if err = w.SavePoint(name).Error(); err != nil {
+		return err
+	}
+	panicked := true
+	defer func() {
+		if panicked || err != nil {
+			if rollbackErr := w.RollbackTo(name).Error(); rollbackErr != nil {
+				err = errors.Join(err, rollbackErr)
+			}
+		}
+	}()
+
+	if err = fc(tx); err == nil {
+		err = w.ReleaseSavePoint(name).Error()
+	}
+	panicked = false
+	return err
+}
+
+func (w writeDB) SavePoint(name string) gormix.WriteOnlyDB {
+	return w.savePointResult(w.savePointDB().SavePoint(name))
+}
+
+func (w writeDB) RollbackTo(name string) gormix.WriteOnlyDB {
+	return w.savePointResult(w.savePointDB().RollbackTo(name))
+}
+
+// ReleaseSavePoint releases name. SQL Server has no such statement, its
+// savepoints last until the transaction ends, so nothing is sent there.
+func (w writeDB) ReleaseSavePoint(name string) gormix.WriteOnlyDB {
+	db := w.savePointDB()
+	if db.Dialector.Name() != "sqlserver" {
+		db.Exec("RELEASE SAVEPOINT " + name)
+	}
+	return w.savePointResult(db)
+}
+
+// savePointDB returns an instance of its own for a savepoint statement. The
+// dialectors run it with Exec on the instance they are given and return nil,
+// so its error only shows on an instance that Exec does not clone.
+func (w writeDB) savePointDB() *gorm.DB {
+	return w.db.Session(&gorm.Session{NewDB: true}).Clauses()
+}
+
+// savePointResult carries the error of a savepoint statement run on db over to
+// a new chain on the same connection.
+func (w writeDB) savePointResult(db *gorm.DB) gormix.WriteOnlyDB {
+	tx := w.db.Session(&gorm.Session{NewDB: true})
+	tx.AddError(db.Error)
+	return &writeDB{tx}
 }
 
 func (w writeDB) Begin(opts ...*sql.TxOptions) gormix.WriteOnlyDB {
@@ -194,7 +377,11 @@ func (w writeDB) Begin(opts ...*sql.TxOptions) gormix.WriteOnlyDB {
 }
 
 func (w writeDB) Commit() error {
-	return w.db.Commit().Error
+	if err := w.db.Commit().Error; err != nil {
+		return err
+	}
+	consistency.Committed(w.db)
+	return nil
 }
 
 func (w writeDB) Rollback() error {